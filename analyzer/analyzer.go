@@ -0,0 +1,856 @@
+// Package analyzer parses Apple .strings and gettext .po localization files
+// and finds duplicate keys, malformed lines and other problems in them. It
+// is the parser behind the localization-analyzer command.
+package analyzer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// KeyValue is one key-value entry of a localization file
+type KeyValue struct {
+	Key       string
+	Value     string
+	LineNum   int
+	Commented bool   // Extracted from a // comment rather than an active line
+	Fuzzy     bool   // Flagged "#, fuzzy" in a .po file
+	File      string // File the entry came from when it was pulled in by #include; empty for the input file
+
+	// Comment lines directly above the entry, trimmed and joined with "\n",
+	// and the line number the comment starts at (0 when there is none)
+	Comment     string
+	CommentLine int
+
+	// Label of the nearest "MARK:" comment above the entry, the section of
+	// the file it belongs to; empty before the first MARK
+	Section string
+
+	// Byte offsets of the value between its quotes on the line, so it can
+	// be rewritten in place; both 0 for .po entries
+	ValueStart int
+	ValueEnd   int
+}
+
+// ParseOptions controls how a localization file is parsed
+type ParseOptions struct {
+	IncludeCommented bool   // Also extract key-value pairs from // comments
+	Encoding         string // Input encoding name; empty means UTF-8, and Windows-1252 for lines that aren't valid UTF-8
+	Separator        string // Character between key and value; empty means "="
+	FollowIncludes   bool   // Merge entries from #include "other.strings" directives
+	Quote            string // Quote style of keys and values: "double" (default), "single" or "any"
+	MaxLineSize      int    // Longest line accepted, in bytes; 0 means DefaultMaxLineSize
+	Format           string // "po" for gettext files; empty means .strings, or detected from a .po/.pot file name
+
+	// Files currently being parsed, outermost first, for include cycle detection
+	includeStack []string
+}
+
+// Result holds everything collected while analyzing a localization file
+type Result struct {
+	Entries        []KeyValue // Every key-value entry in file order
+	KeyOrder       []string   // Unique keys in the order they first appear
+	DuplicateKeys  map[string][]KeyValue
+	UniqueEntries  map[string]KeyValue
+	RawLines       []string
+	MalformedLines []int      // Line numbers that are neither comments nor key-value pairs
+	EmptyKeys      []KeyValue // Entries of the form "" = "value";
+	TrailingLines  []int      // Key-value lines with more than a comment after the closing semicolon
+
+	// Key-value pairs found inside // comments (only with IncludeCommented)
+	CommentedEntries []KeyValue
+
+	// Canonical name of the encoding the input was decoded from
+	Encoding string
+
+	// Lines that weren't valid UTF-8 and were decoded as Windows-1252,
+	// when no encoding was given. Every other line was UTF-8.
+	FallbackLines []int
+
+	// Include chains that lead back to a file already being parsed
+	IncludeCycles []string
+
+	// Line ending styles used in the file
+	LineEndings LineEndingStats
+
+	// Comment blocks not directly followed by a key-value line, e.g. left
+	// behind when the key they described was deleted
+	OrphanedComments []LineRange
+
+	// Format the file was parsed as: "strings" or "po"
+	Format string
+}
+
+// LineRange is a range of line numbers, both ends included
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// LineEndingStats counts the line ending styles of a file
+type LineEndingStats struct {
+	CRLF int // Lines ending in \r\n
+	LF   int // Lines ending in a bare \n
+
+	// First line whose ending differs from the ending of line 1, or 0 when
+	// every line uses the same style
+	FirstChange int
+}
+
+// Mixed reports whether the file uses both \r\n and \n line endings
+func (s LineEndingStats) Mixed() bool {
+	return s.CRLF > 0 && s.LF > 0
+}
+
+// KeysInOrder returns the unique keys in the order they first appear in the
+// file. Keys pulled in by #include appear at the position of the directive.
+// The slice is a copy and may be modified by the caller.
+func (r *Result) KeysInOrder() []string {
+	return append([]string(nil), r.KeyOrder...)
+}
+
+// SortedKeys returns the unique keys sorted by byte order, which is the same
+// for every run on the same input. The slice is a copy and may be modified
+// by the caller.
+func (r *Result) SortedKeys() []string {
+	keys := r.KeysInOrder()
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseFile parses the localization file at filename
+func ParseFile(filename string, opts ParseOptions) (*Result, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	return Parse(filename, file, opts)
+}
+
+// Parse parses localization data read from input. The filename is used to
+// resolve #include directives, to detect gettext files by their extension
+// and in error messages; use "-" for data without a file.
+func Parse(filename string, input io.Reader, opts ParseOptions) (*Result, error) {
+	return parse(filename, input, opts, nil, nil, true)
+}
+
+// ParseWithCallback reports findings in the .strings data read from r as
+// they are parsed instead of returning everything at the end: onEntry is
+// called for every key-value entry in file order, and onDuplicate every
+// time a key gets another occurrence, with all its occurrences so far.
+// Either callback may be nil. The input is read and decoded one line at a
+// time with the default ParseOptions, so callbacks arrive as soon as the
+// line holding the entry has been read, and no Result is built. Use
+// ParseStream to pass a file name and options, and Parse for the Result.
+func ParseWithCallback(r io.Reader, onEntry func(KeyValue), onDuplicate func(key string, entries []KeyValue)) error {
+	return ParseStream("-", r, ParseOptions{}, onEntry, onDuplicate)
+}
+
+// ParseStream is ParseWithCallback for the given file name and options.
+// Neither the entries nor the raw lines are kept, so memory use doesn't
+// grow with the file. Only onDuplicate needs every occurrence of every key
+// kept, so pass nil for it unless duplicates must be reported as they are
+// found.
+func ParseStream(filename string, input io.Reader, opts ParseOptions, onEntry func(KeyValue), onDuplicate func(key string, entries []KeyValue)) error {
+	_, err := parse(filename, input, opts, onEntry, onDuplicate, false)
+	return err
+}
+
+// parse implements Parse and, with keep false, ParseStream, which keeps
+// none of the entries and lines it collects
+func parse(filename string, input io.Reader, opts ParseOptions, onEntry func(KeyValue), onDuplicate func(key string, entries []KeyValue), keep bool) (*Result, error) {
+	if opts.Format == "" && isGettextFile(filename) {
+		opts.Format = "po"
+	}
+
+	// Map to track keys and all their occurrences
+	keyEntries := make(map[string][]KeyValue)
+
+	// Map to track duplicate keys (keys with multiple entries)
+	duplicateKeys := make(map[string][]KeyValue)
+
+	// Map to store unique entries (first occurrence of each key)
+	uniqueEntries := make(map[string]KeyValue)
+
+	// All entries in the order they appear in the file
+	var entries []KeyValue
+
+	// Unique keys in the order they first appear in the file
+	var keyOrder []string
+
+	// Key-value pairs found inside // comments
+	var commentedEntries []KeyValue
+
+	// Include chains that loop back on themselves
+	var includeCycles []string
+
+	// Store all raw lines for recreating the file
+	var rawLines []string
+
+	// Lines that are not comments, blank lines or key-value pairs
+	var malformedLines []int
+	inBlockComment := false
+
+	// Entries with an empty key, which kvPattern doesn't match
+	var emptyKeys []KeyValue
+
+	// First line of the comment block right above the current line, 0 if the
+	// previous line isn't a comment
+	commentStart := 0
+
+	// Comment blocks that aren't followed by a key-value line. A comment
+	// before anything else in the file is its header, not an orphan.
+	var orphanedComments []LineRange
+	contentSeen := false
+	endComment := func(lastLine int) {
+		if commentStart != 0 && contentSeen {
+			orphanedComments = append(orphanedComments, LineRange{Start: commentStart, End: lastLine})
+		}
+		commentStart = 0
+	}
+
+	// Key-value lines with leftovers such as a second ";" after the entry
+	var trailingLines []int
+
+	// Label of the last "MARK:" comment seen
+	section := ""
+
+	// Regular expression to extract key-value pairs
+	// This pattern matches: "key" = "value";
+	kvPattern, err := buildKVPattern(opts.Separator, opts.Quote)
+	if err != nil {
+		return nil, err
+	}
+	emptyKeyPattern := buildEmptyKeyPattern(opts.Separator, opts.Quote)
+
+	lines, err := newLineReader(input, opts.Encoding, opts.MaxLineSize)
+	if err != nil {
+		return nil, err
+	}
+
+	addEntry := func(entry KeyValue) {
+		key := entry.Key
+
+		// Store first occurrence in uniqueEntries
+		if keep {
+			if _, exists := uniqueEntries[key]; !exists {
+				uniqueEntries[key] = entry
+				keyOrder = append(keyOrder, key)
+			}
+			entries = append(entries, entry)
+		}
+
+		if keep || onDuplicate != nil {
+			keyEntries[key] = append(keyEntries[key], entry)
+		}
+
+		// If we now have more than one entry for this key, it's a duplicate
+		if keep && len(keyEntries[key]) > 1 {
+			duplicateKeys[key] = keyEntries[key]
+		}
+
+		if onEntry != nil {
+			onEntry(entry)
+		}
+		if onDuplicate != nil && len(keyEntries[key]) > 1 {
+			onDuplicate(key, append([]KeyValue(nil), keyEntries[key]...))
+		}
+	}
+
+	if opts.FollowIncludes && len(opts.includeStack) == 0 {
+		opts.includeStack = []string{filename}
+	}
+
+	format := "strings"
+	if opts.Format == "po" {
+		format = "po"
+		rawLines, malformedLines, err = parseGettext(lines, addEntry, keep)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		lineNum := 0
+
+		// Lines from the start of the current comment block on, for the
+		// comment of the entry that follows it
+		var commentLines []string
+
+		for lines.Scan() {
+			lineNum++
+			line := lines.Text()
+			if keep {
+				rawLines = append(rawLines, line)
+			}
+			if commentStart == 0 {
+				commentLines = commentLines[:0]
+			}
+			commentLines = append(commentLines, line)
+
+			// Skip comment lines or empty lines for key analysis
+			trimmedLine := strings.TrimSpace(line)
+			if trimmedLine == "" {
+				if !inBlockComment {
+					endComment(lineNum - 1)
+				}
+				continue
+			}
+
+			// Merge the entries of #include "other.strings", relative to this file
+			if opts.FollowIncludes {
+				if matches := includePattern.FindStringSubmatch(trimmedLine); matches != nil {
+					includePath := filepath.Join(filepath.Dir(filename), matches[1])
+
+					if cycleStart := indexOfFile(opts.includeStack, includePath); cycleStart >= 0 {
+						chain := append(append([]string(nil), opts.includeStack[cycleStart:]...), includePath)
+						includeCycles = append(includeCycles, strings.Join(chain, " -> "))
+						continue
+					}
+
+					// Included entries are passed to the callbacks when they are merged below
+					includeOpts := opts
+					includeOpts.includeStack = append(append([]string(nil), opts.includeStack...), includePath)
+					included, err := ParseFile(includePath, includeOpts)
+					if err != nil {
+						return nil, fmt.Errorf("failed to include %s from line %d: %w", matches[1], lineNum, err)
+					}
+
+					for _, entry := range included.Entries {
+						if entry.File == "" {
+							entry.File = includePath
+						}
+						addEntry(entry)
+					}
+					for _, entry := range included.CommentedEntries {
+						if entry.File == "" {
+							entry.File = includePath
+						}
+						commentedEntries = append(commentedEntries, entry)
+					}
+					for _, entry := range included.EmptyKeys {
+						if entry.File == "" {
+							entry.File = includePath
+						}
+						emptyKeys = append(emptyKeys, entry)
+					}
+					includeCycles = append(includeCycles, included.IncludeCycles...)
+					endComment(lineNum - 1)
+					contentSeen = true
+					continue
+				}
+			}
+
+			if strings.HasPrefix(trimmedLine, "//") {
+				if commentStart == 0 {
+					commentStart = lineNum
+				}
+				if mark := markPattern.FindStringSubmatch(trimmedLine); mark != nil {
+					section = mark[1]
+				}
+				if opts.IncludeCommented {
+					if matches := kvPattern.FindStringSubmatch(trimmedLine); len(matches) == 3 {
+						commentedEntries = append(commentedEntries, KeyValue{
+							Key:       matches[1],
+							Value:     matches[2],
+							LineNum:   lineNum,
+							Commented: true,
+						})
+					}
+				}
+				continue
+			}
+
+			// Track /* ... */ blocks so their lines aren't reported as malformed
+			commentLine := inBlockComment || strings.HasPrefix(trimmedLine, "/*")
+			if inBlockComment {
+				inBlockComment = !strings.Contains(trimmedLine, "*/")
+			} else if commentLine {
+				inBlockComment = !strings.Contains(trimmedLine[2:], "*/")
+			}
+
+			// Key-value pairs inside the comment are commented out, only one
+			// after the closing */ counts
+			loc := kvPattern.FindStringSubmatchIndex(line)
+			if loc != nil && commentLine {
+				if end := strings.Index(line, "*/"); end < 0 || loc[0] < end+2 {
+					loc = nil
+				}
+			}
+			var matches []string
+			if loc != nil {
+				matches = []string{line[loc[0]:loc[1]], line[loc[2]:loc[3]], line[loc[4]:loc[5]]}
+			}
+			if len(matches) != 3 && !commentLine {
+				endComment(lineNum - 1)
+				contentSeen = true
+				if emptyMatches := emptyKeyPattern.FindStringSubmatch(line); emptyMatches != nil {
+					emptyKeys = append(emptyKeys, KeyValue{Value: emptyMatches[2], LineNum: lineNum})
+					continue
+				}
+				malformedLines = append(malformedLines, lineNum)
+			}
+			if len(matches) != 3 && commentLine && commentStart == 0 {
+				commentStart = lineNum
+			}
+			if len(matches) != 3 && commentLine {
+				if mark := markPattern.FindStringSubmatch(trimmedLine); mark != nil {
+					section = mark[1]
+				}
+			}
+			if len(matches) == 3 {
+				key := matches[1]
+				value := matches[2]
+
+				// kvPattern stops at the first ";", so anything after it is ignored
+				if hasTrailingContent(line, matches[0]) {
+					trailingLines = append(trailingLines, lineNum)
+				}
+
+				entry := KeyValue{
+					Key:        key,
+					Value:      value,
+					LineNum:    lineNum,
+					Section:    section,
+					ValueStart: loc[4],
+					ValueEnd:   loc[5],
+				}
+				if commentStart != 0 {
+					entry.Comment = commentText(commentLines[:lineNum-commentStart])
+					entry.CommentLine = commentStart
+					commentStart = 0
+				}
+				contentSeen = true
+
+				// Add this entry to keyEntries
+				addEntry(entry)
+			}
+		}
+
+		if err := lines.Err(); err != nil {
+			return nil, err
+		}
+		endComment(lineNum)
+	}
+
+	// Keep every duplicate group in line order, so reports don't depend on
+	// the order entries were collected in. Entries of the file itself come
+	// before entries from included files.
+	for _, group := range duplicateKeys {
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].File != group[j].File {
+				return group[i].File == "" || (group[j].File != "" && group[i].File < group[j].File)
+			}
+			return group[i].LineNum < group[j].LineNum
+		})
+	}
+
+	return &Result{
+		Entries:        entries,
+		KeyOrder:       keyOrder,
+		DuplicateKeys:  duplicateKeys,
+		UniqueEntries:  uniqueEntries,
+		RawLines:       rawLines,
+		MalformedLines: malformedLines,
+		EmptyKeys:      emptyKeys,
+		TrailingLines:  trailingLines,
+
+		CommentedEntries: commentedEntries,
+		Encoding:         lines.encodingName,
+		FallbackLines:    lines.fallbackLines,
+		IncludeCycles:    includeCycles,
+		LineEndings:      lines.endings,
+		OrphanedComments: orphanedComments,
+		Format:           format,
+	}, nil
+}
+
+// isGettextFile reports whether filename has a gettext .po or .pot extension
+func isGettextFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".po" || ext == ".pot"
+}
+
+// gettextLinePattern matches a gettext keyword line such as msgid "text" or
+// msgstr[1] "text", or a continuation line with only a quoted string
+var gettextLinePattern = regexp.MustCompile(`^(msgctxt|msgid_plural|msgid|msgstr(?:\[(\d+)\])?)?\s*"(.*)"$`)
+
+// parseGettext parses a gettext .po file and passes every translation to
+// addEntry. The key is the msgid, prefixed with "context|" when the entry
+// has a msgctxt. Each plural form becomes its own entry with the form index
+// appended to the key, as in "%d files[1]". Values keep their escapes, like
+// .strings values do. An empty msgstr is kept as an entry with an empty
+// value, and the header entry (empty msgid) and obsolete #~ entries are
+// skipped. It returns the raw lines, when keep is set, and the line
+// numbers it couldn't parse.
+func parseGettext(lines *lineReader, addEntry func(KeyValue), keep bool) ([]string, []int, error) {
+	var rawLines []string
+	var malformedLines []int
+
+	// The entry being read
+	var context, id, plural string
+	var translations map[int]*string // By plural form, 0 without plurals
+	var target *string               // Where continuation lines are appended
+	fuzzy, hasID, hasContext := false, false, false
+	entryLine := 0
+
+	flush := func() {
+		if hasID && (id != "" || hasContext) {
+			key := id
+			if hasContext {
+				key = context + "|" + id
+			}
+			if plural == "" {
+				value := ""
+				if translations[0] != nil {
+					value = *translations[0]
+				}
+				addEntry(KeyValue{Key: key, Value: value, LineNum: entryLine, Fuzzy: fuzzy})
+			} else {
+				forms := make([]int, 0, len(translations))
+				for form := range translations {
+					forms = append(forms, form)
+				}
+				sort.Ints(forms)
+				for _, form := range forms {
+					addEntry(KeyValue{Key: fmt.Sprintf("%s[%d]", key, form), Value: *translations[form], LineNum: entryLine, Fuzzy: fuzzy})
+				}
+			}
+		}
+		context, id, plural = "", "", ""
+		translations = make(map[int]*string)
+		target = nil
+		fuzzy, hasID, hasContext = false, false, false
+		entryLine = 0
+	}
+	flush()
+
+	lineNum := 0
+	for lines.Scan() {
+		lineNum++
+		line := lines.Text()
+		if keep {
+			rawLines = append(rawLines, line)
+		}
+		trimmedLine := strings.TrimSpace(line)
+
+		switch {
+		case trimmedLine == "":
+			flush()
+			continue
+		case strings.HasPrefix(trimmedLine, "#"):
+			// A comment after a msgstr starts the next entry
+			if len(translations) > 0 {
+				flush()
+			}
+			if strings.HasPrefix(trimmedLine, "#,") && strings.Contains(trimmedLine, "fuzzy") {
+				fuzzy = true
+			}
+			target = nil
+			continue
+		}
+
+		matches := gettextLinePattern.FindStringSubmatch(trimmedLine)
+		if matches == nil {
+			malformedLines = append(malformedLines, lineNum)
+			continue
+		}
+
+		keyword, text := matches[1], matches[3]
+		switch {
+		case keyword == "":
+			if target == nil {
+				malformedLines = append(malformedLines, lineNum)
+				continue
+			}
+			*target += text
+		case keyword == "msgctxt":
+			if hasID {
+				flush()
+			}
+			context, hasContext = text, true
+			target = &context
+		case keyword == "msgid":
+			if hasID {
+				flush()
+			}
+			id, hasID = text, true
+			entryLine = lineNum
+			target = &id
+		case keyword == "msgid_plural":
+			plural = text
+			target = &plural
+		default:
+			form := 0
+			if matches[2] != "" {
+				form, _ = strconv.Atoi(matches[2])
+			}
+			value := text
+			translations[form] = &value
+			target = &value
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return nil, nil, err
+	}
+	flush()
+
+	return rawLines, malformedLines, nil
+}
+
+// markPattern matches a section marker comment such as "/* MARK: Settings */"
+// or "// MARK: - Onboarding" and captures its label
+var markPattern = regexp.MustCompile(`MARK:\s*(?:-\s*)?(.*?)\s*(?:\*/)?\s*$`)
+
+// commentText joins comment lines with their indentation removed, so that
+// the same comment indented differently still compares equal
+func commentText(lines []string) string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(trimmed, "\n")
+}
+
+// DefaultMaxLineSize is the longest line accepted when ParseOptions.MaxLineSize
+// is 0. It is well above bufio.Scanner's 64KB default, which single-line
+// giant values can exceed.
+const DefaultMaxLineSize = 16 * 1024 * 1024
+
+// lineReader reads a file one line at a time, decoded to UTF-8, and counts
+// the line ending styles of the lines read so far
+type lineReader struct {
+	scanner     *bufio.Scanner
+	maxLineSize int
+	lineNum     int
+	line        string
+
+	// Decoder for lines that aren't valid UTF-8 when the encoding is
+	// detected, nil when it was given
+	fallback *encoding.Decoder
+
+	// Canonical name of the encoding the lines were decoded from, and the
+	// lines decoded with fallback
+	encodingName  string
+	fallbackLines []int
+
+	endings   LineEndingStats
+	firstCRLF bool
+	endCount  int // Line endings seen
+}
+
+// newLineReader returns a reader for the lines of input in the named
+// encoding. Without a name, lines that are valid UTF-8 are used as-is and
+// any other line is taken to be legacy Windows-1252 (Latin-1), which is
+// then reported as the encoding. Lines may be up to maxLineSize bytes long,
+// or DefaultMaxLineSize when maxLineSize is 0.
+func newLineReader(input io.Reader, name string, maxLineSize int) (*lineReader, error) {
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	r := &lineReader{maxLineSize: maxLineSize, encodingName: "utf-8"}
+
+	if name == "" {
+		r.fallback = charmap.Windows1252.NewDecoder()
+	} else {
+		enc, err := htmlindex.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown encoding %q", name)
+		}
+		r.encodingName, _ = htmlindex.Name(enc)
+		if r.encodingName != "utf-8" {
+			input = enc.NewDecoder().Reader(input)
+		}
+	}
+
+	r.scanner = bufio.NewScanner(input)
+	r.scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	r.scanner.Split(r.splitLine)
+	return r, nil
+}
+
+// splitLine is bufio.ScanLines, counting the ending of every line it splits off
+func (r *lineReader) splitLine(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if advance > 0 && data[advance-1] == '\n' {
+		crlf := advance > 1 && data[advance-2] == '\r'
+		if crlf {
+			r.endings.CRLF++
+		} else {
+			r.endings.LF++
+		}
+
+		r.endCount++
+		if r.endCount == 1 {
+			r.firstCRLF = crlf
+		} else if crlf != r.firstCRLF && r.endings.FirstChange == 0 {
+			r.endings.FirstChange = r.endCount
+		}
+	}
+	return advance, token, err
+}
+
+// Scan reads the next line, which Text then returns. It returns false at
+// the end of the input or on an error, which Err returns.
+func (r *lineReader) Scan() bool {
+	if !r.scanner.Scan() {
+		return false
+	}
+	r.lineNum++
+
+	line := r.scanner.Bytes()
+	if r.fallback != nil && !utf8.Valid(line) {
+		decoded, err := r.fallback.Bytes(line)
+		if err == nil {
+			line = decoded
+			r.encodingName = "windows-1252"
+			r.fallbackLines = append(r.fallbackLines, r.lineNum)
+		}
+	}
+	r.line = string(line)
+	return true
+}
+
+// Text returns the line read by the last call to Scan
+func (r *lineReader) Text() string {
+	return r.line
+}
+
+// Err returns the error that stopped Scan, pointing at -max-line when a
+// line was too long
+func (r *lineReader) Err() error {
+	err := r.scanner.Err()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("line %d is longer than %d bytes; use -max-line to raise the limit", r.lineNum+1, r.maxLineSize)
+	}
+	return fmt.Errorf("error reading file: %w", err)
+}
+
+// includePattern matches an #include "other.strings" directive
+var includePattern = regexp.MustCompile(`^#include\s+"([^"]+)"`)
+
+// indexOfFile returns the position of the file in files that refers to the
+// same path as filename, or -1
+func indexOfFile(files []string, filename string) int {
+	target, err := filepath.Abs(filename)
+	if err != nil {
+		return -1
+	}
+	for i, file := range files {
+		if path, err := filepath.Abs(file); err == nil && path == target {
+			return i
+		}
+	}
+	return -1
+}
+
+// kvMatcher extracts a key and value from a line. Like a regular expression
+// with two groups, FindStringSubmatch returns the whole match, the key and
+// the value, or nil when the line isn't a key-value pair, and
+// FindStringSubmatchIndex returns their byte offsets.
+type kvMatcher struct {
+	pattern *regexp.Regexp
+	// With "any" quotes the pattern has one group per quote style for both
+	// the key and the value, and only one of each pair can match
+	alternatives bool
+}
+
+func (m *kvMatcher) FindStringSubmatch(line string) []string {
+	loc := m.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return nil
+	}
+	return []string{line[loc[0]:loc[1]], line[loc[2]:loc[3]], line[loc[4]:loc[5]]}
+}
+
+func (m *kvMatcher) FindStringSubmatchIndex(line string) []int {
+	loc := m.pattern.FindStringSubmatchIndex(line)
+	if loc == nil || !m.alternatives {
+		return loc
+	}
+	// Take whichever group of each pair matched
+	pick := func(first int) (int, int) {
+		if loc[first] >= 0 {
+			return loc[first], loc[first+1]
+		}
+		return loc[first+2], loc[first+3]
+	}
+	keyStart, keyEnd := pick(2)
+	valueStart, valueEnd := pick(6)
+	return []int{loc[0], loc[1], keyStart, keyEnd, valueStart, valueEnd}
+}
+
+// quotedPattern returns the regular expression for a quoted key or value
+// in the given quote style, using body for the text between the quotes
+func quotedPattern(quote, body string) (string, error) {
+	switch quote {
+	case "", "double":
+		return `"(` + strings.ReplaceAll(body, "Q", `"`) + `)"`, nil
+	case "single":
+		return `'(` + strings.ReplaceAll(body, "Q", `'`) + `)'`, nil
+	case "any":
+		return `(?:"(` + strings.ReplaceAll(body, "Q", `"`) + `)"|'(` + strings.ReplaceAll(body, "Q", `'`) + `)')`, nil
+	}
+	return "", fmt.Errorf("invalid quote style %q: must be double, single or any", quote)
+}
+
+// buildKVPattern returns the key-value matcher for the given separator and
+// quote style. The separator must be a single character that can't be
+// confused with the rest of the syntax.
+func buildKVPattern(separator, quote string) (*kvMatcher, error) {
+	if separator == "" {
+		separator = "="
+	}
+
+	if utf8.RuneCountInString(separator) != 1 || strings.ContainsAny(separator, "\"'\\;") || strings.TrimSpace(separator) == "" {
+		return nil, fmt.Errorf("invalid separator %q: must be a single character other than a quote, backslash, semicolon or whitespace", separator)
+	}
+
+	quoted, err := quotedPattern(quote, `[^Q]+`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvMatcher{
+		pattern:      regexp.MustCompile(quoted + `\s*` + regexp.QuoteMeta(separator) + `\s*` + quoted + `\s*;`),
+		alternatives: quote == "any",
+	}, nil
+}
+
+// buildEmptyKeyPattern returns a matcher for an entry with an empty key,
+// capturing its value as the second group. The separator and quote style
+// must already have been validated by buildKVPattern.
+func buildEmptyKeyPattern(separator, quote string) *kvMatcher {
+	if separator == "" {
+		separator = "="
+	}
+	emptyKey, _ := quotedPattern(quote, ``)
+	value, _ := quotedPattern(quote, `[^Q]*`)
+	return &kvMatcher{
+		pattern:      regexp.MustCompile(`^\s*` + emptyKey + `\s*` + regexp.QuoteMeta(separator) + `\s*` + value + `\s*;`),
+		alternatives: quote == "any",
+	}
+}
+
+// hasTrailingContent reports whether something other than a comment follows
+// the matched key-value pair on the line. The key-value pattern stops at the
+// first ";", so such content would otherwise be silently ignored.
+func hasTrailingContent(line, match string) bool {
+	rest := strings.TrimSpace(line[strings.Index(line, match)+len(match):])
+	return rest != "" && !strings.HasPrefix(rest, "//") && !strings.HasPrefix(rest, "/*")
+}
@@ -0,0 +1,279 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseString(t *testing.T, content string, opts ParseOptions) *Result {
+	t.Helper()
+	result, err := Parse("test.strings", strings.NewReader(content), opts)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	result := parseString(t, `/* Header */
+
+// Greeting
+"hello" = "Hello";
+"bye" = "Bye";
+"hello" = "Hi";
+not a pair
+"" = "no key";
+`, ParseOptions{})
+
+	if len(result.Entries) != 3 {
+		t.Errorf("got %d entries, want 3", len(result.Entries))
+	}
+	if got := result.UniqueEntries["hello"]; got.Value != "Hello" || got.LineNum != 4 || got.Comment != "// Greeting" {
+		t.Errorf("first hello = %+v", got)
+	}
+	if got := len(result.DuplicateKeys["hello"]); got != 2 {
+		t.Errorf("hello has %d occurrences, want 2", got)
+	}
+	if !reflect.DeepEqual(result.MalformedLines, []int{7}) {
+		t.Errorf("malformed lines %v, want [7]", result.MalformedLines)
+	}
+	if len(result.EmptyKeys) != 1 || result.EmptyKeys[0].LineNum != 8 {
+		t.Errorf("empty keys %+v, want one on line 8", result.EmptyKeys)
+	}
+	if result.Format != "strings" || result.Encoding != "utf-8" {
+		t.Errorf("format %q, encoding %q", result.Format, result.Encoding)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    ParseOptions
+		want    map[string]string
+	}{
+		{"separator", "\"a\" : \"1\";\n", ParseOptions{Separator: ":"}, map[string]string{"a": "1"}},
+		{"single quotes", "'a' = '1';\n", ParseOptions{Quote: "single"}, map[string]string{"a": "1"}},
+		{"any quotes", "'a' = \"1\";\n\"b\" = '2';\n", ParseOptions{Quote: "any"}, map[string]string{"a": "1", "b": "2"}},
+		{"commented", "// \"a\" = \"1\";\n\"b\" = \"2\";\n", ParseOptions{IncludeCommented: true}, map[string]string{"b": "2"}},
+	}
+	for _, test := range tests {
+		result := parseString(t, test.content, test.opts)
+		got := make(map[string]string)
+		for key, entry := range result.UniqueEntries {
+			got[key] = entry.Value
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if _, err := Parse("test.strings", strings.NewReader(""), ParseOptions{Separator: ";"}); err == nil {
+		t.Error("invalid separator accepted")
+	}
+}
+
+func TestValueOffsets(t *testing.T) {
+	tests := []struct {
+		line  string
+		opts  ParseOptions
+		start int
+	}{
+		{`"k" = "Hi"; // old: "Hi"`, ParseOptions{}, 7},
+		{`"Hi"="Hi";`, ParseOptions{}, 6},
+		{`'k' = "Hi";`, ParseOptions{Quote: "any"}, 7},
+		{`"k" = 'Hi'; // 'Hi'`, ParseOptions{Quote: "any"}, 7},
+	}
+	for _, test := range tests {
+		result := parseString(t, test.line+"\n", test.opts)
+		if len(result.Entries) != 1 {
+			t.Fatalf("%s: got %d entries, want 1", test.line, len(result.Entries))
+		}
+		entry := result.Entries[0]
+		if entry.ValueStart != test.start || entry.ValueEnd != test.start+2 {
+			t.Errorf("%s: value at %d-%d, want %d-%d", test.line, entry.ValueStart, entry.ValueEnd, test.start, test.start+2)
+		}
+	}
+}
+
+func TestParseGettext(t *testing.T) {
+	result, err := Parse("fr.po", strings.NewReader(`msgid ""
+msgstr "Content-Type: text/plain; charset=UTF-8\n"
+
+#, fuzzy
+msgid "Hello"
+msgstr "Bonjour"
+
+msgctxt "menu"
+msgid "Open"
+msgstr ""
+`), ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Format != "po" {
+		t.Errorf("format %q, want po from the file name", result.Format)
+	}
+	if got := result.UniqueEntries["Hello"]; got.Value != "Bonjour" || !got.Fuzzy {
+		t.Errorf("Hello = %+v", got)
+	}
+	if _, ok := result.UniqueEntries["menu|Open"]; !ok {
+		t.Errorf("context entry missing: %v", result.KeyOrder)
+	}
+}
+
+func TestKeysInOrder(t *testing.T) {
+	result := parseString(t, "\"b\" = \"1\";\n\"a\" = \"2\";\n\"b\" = \"3\";\n\"c\" = \"4\";\n", ParseOptions{})
+
+	if got := result.KeysInOrder(); !reflect.DeepEqual(got, []string{"b", "a", "c"}) {
+		t.Errorf("KeysInOrder() = %v", got)
+	}
+	if got := result.SortedKeys(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("SortedKeys() = %v", got)
+	}
+
+	// The slices are copies
+	result.KeysInOrder()[0] = "changed"
+	if result.KeyOrder[0] != "b" {
+		t.Error("KeysInOrder returned the result's own slice")
+	}
+}
+
+func TestParseWithCallback(t *testing.T) {
+	var entries []string
+	var duplicates []int
+	err := ParseWithCallback(strings.NewReader("\"a\" = \"1\";\n\"b\" = \"2\";\n\"a\" = \"3\";\n\"a\" = \"4\";\n"),
+		func(entry KeyValue) { entries = append(entries, entry.Key) },
+		func(key string, occurrences []KeyValue) { duplicates = append(duplicates, len(occurrences)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries, []string{"a", "b", "a", "a"}) {
+		t.Errorf("onEntry got %v", entries)
+	}
+	if !reflect.DeepEqual(duplicates, []int{2, 3}) {
+		t.Errorf("onDuplicate got group sizes %v, want [2 3]", duplicates)
+	}
+}
+
+func TestParseWithCallbackStreams(t *testing.T) {
+	reader, writer := io.Pipe()
+	entries := make(chan KeyValue)
+	done := make(chan error)
+	go func() {
+		done <- ParseWithCallback(reader, func(entry KeyValue) { entries <- entry }, nil)
+	}()
+
+	// Each entry is reported before the next line is written
+	for i, key := range []string{"first", "second"} {
+		fmt.Fprintf(writer, "\"%s\" = \"%d\";\n", key, i)
+		select {
+		case entry := <-entries:
+			if entry.Key != key {
+				t.Errorf("got entry %q, want %q", entry.Key, key)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no callback for %q before the input ended", key)
+		}
+	}
+	writer.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	content := `/* Header */
+
+// Greeting
+/* and a block */
+"hello" = "Hello";
+"bye" = "Bye";
+// Again
+"hello" = "Hi";
+`
+	want := parseString(t, content, ParseOptions{}).Entries
+
+	var got []KeyValue
+	duplicates := 0
+	err := ParseStream("test.strings", strings.NewReader(content), ParseOptions{},
+		func(entry KeyValue) { got = append(got, entry) },
+		func(key string, entries []KeyValue) { duplicates++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("streamed entries %+v, want %+v", got, want)
+	}
+	if duplicates != 1 {
+		t.Errorf("%d duplicate callbacks, want 1", duplicates)
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	result := parseString(t, "\"a\" = \"1\";\r\n\"b\" = \"2\";\r\n\"c\" = \"3\";\n", ParseOptions{})
+	want := LineEndingStats{CRLF: 2, LF: 1, FirstChange: 3}
+	if result.LineEndings != want || !result.LineEndings.Mixed() {
+		t.Errorf("line endings %+v, want %+v", result.LineEndings, want)
+	}
+}
+
+func TestParseLatin1(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		encoding string
+		want     map[string]string
+		wantName string
+	}{
+		{"detected", "\"caf\xe9\" = \"se\xf1or\";\n\"m\xfcde\" = \"\xfcber\";\n", "", map[string]string{"café": "señor", "müde": "über"}, "windows-1252"},
+		{"latin1", "\"caf\xe9\" = \"se\xf1or\";\n", "latin1", map[string]string{"café": "señor"}, "windows-1252"},
+		{"windows-1252", "\"m\xfcde\" = \"\xfcber\";\n", "windows-1252", map[string]string{"müde": "über"}, "windows-1252"},
+		{"utf-8 stays utf-8", "\"café\" = \"señor\";\n", "", map[string]string{"café": "señor"}, "utf-8"},
+		{"mixed lines", "\"café\" = \"1\";\n\"se\xf1or\" = \"2\";\n", "", map[string]string{"café": "1", "señor": "2"}, "windows-1252"},
+	}
+	for _, test := range tests {
+		result := parseString(t, test.content, ParseOptions{Encoding: test.encoding})
+		got := make(map[string]string)
+		for key, entry := range result.UniqueEntries {
+			got[key] = entry.Value
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		if result.Encoding != test.wantName {
+			t.Errorf("%s: encoding %q, want %q", test.name, result.Encoding, test.wantName)
+		}
+	}
+
+	if _, err := Parse("test.strings", strings.NewReader(""), ParseOptions{Encoding: "no-such-encoding"}); err == nil {
+		t.Error("unknown encoding accepted")
+	}
+}
+
+func TestParseLongLines(t *testing.T) {
+	// Well past bufio.Scanner's 64KB default token size
+	long := strings.Repeat("x", 200*1024)
+	content := "\"short\" = \"1\";\n\"long\" = \"" + long + "\";\n\"after\" = \"2\";\n"
+
+	result := parseString(t, content, ParseOptions{})
+	if got := result.UniqueEntries["long"].Value; got != long {
+		t.Errorf("long value has %d bytes, want %d", len(got), len(long))
+	}
+	if _, ok := result.UniqueEntries["after"]; !ok {
+		t.Error("entry after the long line is missing")
+	}
+
+	// A limit below the line length reports the line instead
+	_, err := Parse("test.strings", strings.NewReader(content), ParseOptions{MaxLineSize: 64 * 1024})
+	if err == nil || !strings.Contains(err.Error(), "line 2 is longer than 65536 bytes") {
+		t.Errorf("got error %v, want one naming line 2", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "token too long") {
+		t.Errorf("error %q leaks the scanner's message", err)
+	}
+}
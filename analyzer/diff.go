@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"sort"
+	"strings"
+)
+
+// ChangedKey describes a key present in both files with different values
+type ChangedKey struct {
+	Key      string
+	OldValue string
+	NewValue string
+}
+
+// DiffResult is the structured outcome of comparing two analysis results.
+// Keys in each category are sorted alphabetically.
+type DiffResult struct {
+	Added   []KeyValue   // Keys only present in the other file
+	Removed []KeyValue   // Keys only present in the base file
+	Changed []ChangedKey // Keys present in both files with different values
+
+	// Keys whose values only differ in the order of their positional or
+	// named placeholders, with DiffOptions.AllowReorderedPlaceholders
+	Reordered []ChangedKey
+}
+
+// DiffOptions controls how values are compared by DiffWithOptions
+type DiffOptions struct {
+	// IgnoreWhitespace trims values and collapses runs of whitespace into a
+	// single space before comparing them
+	IgnoreWhitespace bool
+
+	// AllowReorderedPlaceholders reports values that only differ because
+	// positional (%1$@) or named (%{name}) placeholders were reordered, which
+	// translations are free to do, as Reordered instead of Changed
+	AllowReorderedPlaceholders bool
+}
+
+// Diff compares the unique entries of two results. For duplicated keys the
+// first occurrence is used, matching what the cleaned file would keep.
+func Diff(base, other *Result) DiffResult {
+	return DiffWithOptions(base, other, DiffOptions{})
+}
+
+// DiffWithOptions is Diff with configurable value comparison
+func DiffWithOptions(base, other *Result, opts DiffOptions) DiffResult {
+	var diff DiffResult
+
+	normalize := func(value string) string {
+		if opts.IgnoreWhitespace {
+			return strings.Join(strings.Fields(value), " ")
+		}
+		return value
+	}
+
+	for key, baseEntry := range base.UniqueEntries {
+		otherEntry, exists := other.UniqueEntries[key]
+		if !exists {
+			diff.Removed = append(diff.Removed, baseEntry)
+		} else if normalize(otherEntry.Value) != normalize(baseEntry.Value) {
+			change := ChangedKey{
+				Key:      key,
+				OldValue: baseEntry.Value,
+				NewValue: otherEntry.Value,
+			}
+			if opts.AllowReorderedPlaceholders && placeholdersReordered(normalize(baseEntry.Value), normalize(otherEntry.Value)) {
+				diff.Reordered = append(diff.Reordered, change)
+			} else {
+				diff.Changed = append(diff.Changed, change)
+			}
+		}
+	}
+
+	for key, otherEntry := range other.UniqueEntries {
+		if _, exists := base.UniqueEntries[key]; !exists {
+			diff.Added = append(diff.Added, otherEntry)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Key < diff.Added[j].Key })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Key < diff.Removed[j].Key })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Key < diff.Changed[j].Key })
+	sort.Slice(diff.Reordered, func(i, j int) bool { return diff.Reordered[i].Key < diff.Reordered[j].Key })
+
+	return diff
+}
+
+// placeholdersReordered reports whether a and b are the same text with the
+// same placeholders in a different order. Every placeholder has to be
+// positional or named, since reordering plain %@ specifiers swaps the
+// arguments they are filled with.
+func placeholdersReordered(a, b string) bool {
+	skeletonA, tokensA, okA := placeholderSkeleton(a)
+	skeletonB, tokensB, okB := placeholderSkeleton(b)
+	if !okA || !okB || skeletonA != skeletonB || len(tokensA) != len(tokensB) {
+		return false
+	}
+
+	counts := make(map[string]int)
+	for _, token := range tokensA {
+		counts[token]++
+	}
+	for _, token := range tokensB {
+		if counts[token] == 0 {
+			return false
+		}
+		counts[token]--
+	}
+	return true
+}
+
+// placeholderSkeleton replaces every placeholder of value with a marker and
+// returns the result and the placeholders in order. ok is false when the
+// value has a placeholder that is neither positional nor named.
+func placeholderSkeleton(value string) (skeleton string, tokens []string, ok bool) {
+	var builder strings.Builder
+	last := 0
+	for _, token := range ExtractTokens(value) {
+		if token.Kind == TokenPrintf && token.Position == 0 {
+			return "", nil, false
+		}
+		builder.WriteString(value[last:token.Start])
+		builder.WriteString("\x00")
+		tokens = append(tokens, token.Text)
+		last = token.End
+	}
+	builder.WriteString(value[last:])
+	return builder.String(), tokens, true
+}
@@ -0,0 +1,43 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	base := parseString(t, "\"same\" = \"1\";\n\"changed\" = \"old\";\n\"removed\" = \"x\";\n\"spaces\" = \"a  b\";\n\"order\" = \"%1$@ %2$@\";\n", ParseOptions{})
+	other := parseString(t, "\"same\" = \"1\";\n\"changed\" = \"new\";\n\"added\" = \"y\";\n\"spaces\" = \"a b \";\n\"order\" = \"%2$@ %1$@\";\n", ParseOptions{})
+
+	diff := Diff(base, other)
+	if keys := diffKeys(diff.Added); !reflect.DeepEqual(keys, []string{"added"}) {
+		t.Errorf("Added = %v", keys)
+	}
+	if keys := diffKeys(diff.Removed); !reflect.DeepEqual(keys, []string{"removed"}) {
+		t.Errorf("Removed = %v", keys)
+	}
+	want := []ChangedKey{
+		{Key: "changed", OldValue: "old", NewValue: "new"},
+		{Key: "order", OldValue: "%1$@ %2$@", NewValue: "%2$@ %1$@"},
+		{Key: "spaces", OldValue: "a  b", NewValue: "a b "},
+	}
+	if !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("Changed = %+v", diff.Changed)
+	}
+
+	diff = DiffWithOptions(base, other, DiffOptions{IgnoreWhitespace: true, AllowReorderedPlaceholders: true})
+	if len(diff.Changed) != 1 || diff.Changed[0].Key != "changed" {
+		t.Errorf("Changed with options = %+v", diff.Changed)
+	}
+	if len(diff.Reordered) != 1 || diff.Reordered[0].Key != "order" {
+		t.Errorf("Reordered = %+v", diff.Reordered)
+	}
+}
+
+func diffKeys(entries []KeyValue) []string {
+	var keys []string
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	return keys
+}
@@ -0,0 +1,61 @@
+package analyzer
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// tokenPattern matches printf-style format specifiers such as %@, %d,
+// %1$@, %lld and %.2f, and %{name} interpolation tokens. An escaped percent
+// sign (%%) is matched so it can be skipped, but isn't a token.
+var tokenPattern = regexp.MustCompile(`%%|%\{([^{}]+)\}|%(?:(\d+)\$)?[-+ #0']*\d*(?:\.\d+)?(?:hh|h|ll|l|q|L|z|t|j)?([@dDiuUxXoOfFeEgGcCsSpaA])`)
+
+// TokenKind tells printf specifiers and named tokens apart
+type TokenKind int
+
+const (
+	TokenPrintf TokenKind = iota // %@, %d, %1$@, ...
+	TokenNamed                   // %{name}
+)
+
+// Token is an interpolation token found in a value
+type Token struct {
+	Kind  TokenKind
+	Text  string // The token as written, e.g. "%1$@"
+	Start int    // Byte offset of the token in the value
+	End   int    // Byte offset just after the token
+
+	// For printf specifiers: the conversion character ("@", "d", "f", ...)
+	// and the explicit argument position of %n$ specifiers, or 0
+	Verb     string
+	Position int
+
+	// For named tokens: the name between the braces
+	Name string
+}
+
+// ExtractTokens returns the printf specifiers and %{named} tokens in value,
+// in the order they appear
+func ExtractTokens(value string) []Token {
+	var tokens []Token
+	for _, loc := range tokenPattern.FindAllStringSubmatchIndex(value, -1) {
+		text := value[loc[0]:loc[1]]
+		if text == "%%" {
+			continue
+		}
+
+		token := Token{Text: text, Start: loc[0], End: loc[1]}
+		if loc[2] >= 0 {
+			token.Kind = TokenNamed
+			token.Name = value[loc[2]:loc[3]]
+		} else {
+			token.Kind = TokenPrintf
+			token.Verb = value[loc[6]:loc[7]]
+			if loc[4] >= 0 {
+				token.Position, _ = strconv.Atoi(value[loc[4]:loc[5]])
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
@@ -0,0 +1,23 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractTokens(t *testing.T) {
+	got := ExtractTokens("%1$@ has %d%% of %{count} and %.2f")
+	want := []Token{
+		{Kind: TokenPrintf, Text: "%1$@", Start: 0, End: 4, Verb: "@", Position: 1},
+		{Kind: TokenPrintf, Text: "%d", Start: 9, End: 11, Verb: "d"},
+		{Kind: TokenNamed, Text: "%{count}", Start: 17, End: 25, Name: "count"},
+		{Kind: TokenPrintf, Text: "%.2f", Start: 30, End: 34, Verb: "f"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractTokens() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if tokens := ExtractTokens("100%% plain"); len(tokens) != 0 {
+		t.Errorf("escaped percent sign gave tokens %+v", tokens)
+	}
+}
@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Issue describes one problem found in a line by ValidateEntry
+type Issue struct {
+	Code    string // Stable identifier such as "missing-semicolon"
+	Message string // Human-readable description
+}
+
+// ValidateEntry checks a single line of a .strings file, with the default
+// separator and quote style, and returns its problems. Blank lines and
+// comments have none. It uses the same checks as the file parser and
+// -explain, so an editor linting as you type reports exactly what the
+// analyzer reports for the saved file.
+func ValidateEntry(line string) []Issue {
+	return ValidateEntryWithOptions(line, ParseOptions{})
+}
+
+// ValidateEntryWithOptions is ValidateEntry for files with the separator
+// and quote style of opts. Invalid options are reported as an issue with
+// the code "invalid-options".
+func ValidateEntryWithOptions(line string, opts ParseOptions) []Issue {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") {
+		return nil
+	}
+
+	kvPattern, err := buildKVPattern(opts.Separator, opts.Quote)
+	if err != nil {
+		return []Issue{{Code: "invalid-options", Message: err.Error()}}
+	}
+	if matches := kvPattern.FindStringSubmatch(line); len(matches) == 3 {
+		if hasTrailingContent(line, matches[0]) {
+			return []Issue{{Code: "trailing-content", Message: "unexpected content after ';'"}}
+		}
+		return nil
+	}
+	if buildEmptyKeyPattern(opts.Separator, opts.Quote).FindStringSubmatch(line) != nil {
+		return []Issue{{Code: "empty-key", Message: "empty key"}}
+	}
+
+	separator := opts.Separator
+	if separator == "" {
+		separator = "="
+	}
+	return []Issue{classifySkippedLine(line, separator, opts.Quote)}
+}
+
+// classifySkippedLine gives a best guess at why a non-comment line didn't
+// parse as a key-value pair
+func classifySkippedLine(line, separator, quote string) Issue {
+	trimmed := strings.TrimSpace(line)
+	quoteChar := "\""
+	if quote == "single" {
+		quoteChar = "'"
+	}
+
+	// Ignore a trailing // comment when looking for the semicolon
+	code := trimmed
+	if idx := strings.LastIndex(code, "//"); idx > 0 && strings.Count(code[:idx], quoteChar)%2 == 0 {
+		code = strings.TrimSpace(code[:idx])
+	}
+
+	switch {
+	case strings.HasPrefix(trimmed, "#include"):
+		return Issue{"include-directive", "#include directive (use -follow-includes to merge the included file)"}
+	case strings.HasPrefix(trimmed, "#"):
+		return Issue{"preprocessor-directive", "preprocessor directive"}
+	case !strings.Contains(trimmed, "\"") && !strings.Contains(trimmed, "'"):
+		return Issue{"no-quotes", "no quoted key or value"}
+	case strings.Contains(trimmed, "\\"+quoteChar):
+		return Issue{"escaped-quote", "escaped quote in the key or value, which the parser doesn't support"}
+	case strings.Count(code, quoteChar)%2 != 0:
+		return Issue{"unbalanced-quotes", "unbalanced quotes (missing or unescaped quote)"}
+	case !strings.Contains(code, separator):
+		return Issue{"missing-separator", fmt.Sprintf("missing %q between key and value", separator)}
+	case strings.Contains(code, quoteChar+quoteChar):
+		return Issue{"empty-value", "empty value"}
+	case !strings.HasSuffix(code, ";"):
+		return Issue{"missing-semicolon", "missing semicolon"}
+	case strings.Count(code, quoteChar) > 4:
+		return Issue{"unescaped-quote", "unescaped quote inside the key or value"}
+	}
+	return Issue{"malformed", "doesn't match \"key\" = \"value\";"}
+}
@@ -0,0 +1,47 @@
+package analyzer
+
+import "testing"
+
+func TestValidateEntry(t *testing.T) {
+	tests := []struct {
+		line string
+		want string // Code of the first issue, empty for none
+	}{
+		{`"key" = "value";`, ""},
+		{`"key" = "value"; // note`, ""},
+		{`// "key" = "value"`, ""},
+		{``, ""},
+		{`"key" = "value"`, "missing-semicolon"},
+		{`"key" = "value";;`, "trailing-content"},
+		{`"" = "value";`, "empty-key"},
+		{`"key" = "";`, "empty-value"},
+		{`"key" "value";`, "missing-separator"},
+		{`"key = "value";`, "unbalanced-quotes"},
+		{`"key" = "say \"hi\"";`, "escaped-quote"},
+		{`#include "other.strings"`, "include-directive"},
+		{`key = value;`, "no-quotes"},
+	}
+	for _, test := range tests {
+		issues := ValidateEntry(test.line)
+		got := ""
+		if len(issues) > 0 {
+			got = issues[0].Code
+		}
+		if got != test.want {
+			t.Errorf("ValidateEntry(%q) = %+v, want code %q", test.line, issues, test.want)
+		}
+	}
+}
+
+func TestValidateEntryWithOptions(t *testing.T) {
+	opts := ParseOptions{Separator: ":", Quote: "single"}
+	if issues := ValidateEntryWithOptions(`'key' : 'value';`, opts); len(issues) != 0 {
+		t.Errorf("valid line got issues %+v", issues)
+	}
+	if issues := ValidateEntryWithOptions(`'key' = 'value';`, opts); len(issues) != 1 || issues[0].Code != "missing-separator" {
+		t.Errorf("got %+v, want missing-separator", issues)
+	}
+	if issues := ValidateEntryWithOptions(`"key" = "value";`, ParseOptions{Quote: "backtick"}); len(issues) != 1 || issues[0].Code != "invalid-options" {
+		t.Errorf("got %+v, want invalid-options", issues)
+	}
+}
@@ -1,3 +1,5 @@
+//go:build ignore
+
 package main
 
 import (
@@ -14,14 +16,61 @@ import (
 func main() {
 	// Parse command-line flags
 	var inputFile string
+	var firstOnly bool
+	var grepValue string
+	var ignoreCase bool
 	flag.StringVar(&inputFile, "f", "Localizable.strings", "Input localization file (default: Localizable.strings)")
+	flag.BoolVar(&firstOnly, "first", false, "Stop at the first occurrence instead of reporting all of them")
+	flag.StringVar(&grepValue, "grep-value", "", "Report every key whose value matches this regular expression instead of checking a key")
+	flag.BoolVar(&ignoreCase, "i", false, "Match -grep-value case-insensitively")
 	flag.Parse()
 
-	// Get the key to check
+	// Search values instead of keys if requested
+	if grepValue != "" {
+		pattern := grepValue
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		valuePattern, err := regexp.Compile(pattern)
+		if err != nil {
+			fmt.Printf("Error: invalid -grep-value pattern: %v\n", err)
+			os.Exit(1)
+		}
+
+		if _, err := os.Stat(inputFile); os.IsNotExist(err) {
+			fmt.Printf("Error: File %s does not exist\n", inputFile)
+			os.Exit(1)
+		}
+
+		matches, err := findValueMatches(inputFile, valuePattern)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(matches) == 0 {
+			fmt.Printf("No values matching \"%s\" found in %s\n", grepValue, inputFile)
+			return
+		}
+		fmt.Printf("Values matching \"%s\" found in %s (%d keys):\n", grepValue, inputFile, len(matches))
+		for _, match := range matches {
+			fmt.Printf("  Line %d: \"%s\" = \"%s\"\n", match.LineNum, match.Key, match.Value)
+		}
+		return
+	}
+
+	// Get the key to check, or read a list of keys from a pipe
 	args := flag.Args()
 	if len(args) == 0 {
+		if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+			checkKeysFromStdin(inputFile, firstOnly)
+			return
+		}
+
 		fmt.Println("Error: No key specified")
-		fmt.Println("Usage: go run check_keys.go [-f filename.strings] \"key_to_check\"")
+		fmt.Println("Usage: go run check_keys.go [-f filename.strings] [-first] \"key_to_check\"")
+		fmt.Println("       cat keys.txt | go run check_keys.go [-f filename.strings] [-first]")
+		fmt.Println("       go run check_keys.go [-f filename.strings] [-i] -grep-value \"regex\"")
 		os.Exit(1)
 	}
 
@@ -34,15 +83,66 @@ func main() {
 	}
 
 	// Look for the key
-	occurrences, err := findKeyOccurrences(inputFile, keyToCheck)
+	occurrences, err := findKeyOccurrences(inputFile, keyToCheck, firstOnly)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printKeyReport(inputFile, keyToCheck, occurrences, firstOnly)
+}
+
+// checkKeysFromStdin reads one key per line from stdin and reports each of
+// them, separated by blank lines. The file is scanned only once.
+func checkKeysFromStdin(inputFile string, firstOnly bool) {
+	var keys []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("Error reading keys from stdin: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
+		fmt.Printf("Error: File %s does not exist\n", inputFile)
+		os.Exit(1)
+	}
+
+	allOccurrences, err := findAllOccurrences(inputFile)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Report findings
+	notFound := 0
+	for i, key := range keys {
+		if i > 0 {
+			fmt.Println()
+		}
+		occurrences := allOccurrences[key]
+		if len(occurrences) == 0 {
+			notFound++
+		}
+		if firstOnly && len(occurrences) > 1 {
+			occurrences = occurrences[:1]
+		}
+		printKeyReport(inputFile, key, occurrences, firstOnly)
+	}
+
+	fmt.Printf("\nChecked %d keys: %d found, %d not found\n", len(keys), len(keys)-notFound, notFound)
+}
+
+// printKeyReport prints the occurrences of one key
+func printKeyReport(inputFile, keyToCheck string, occurrences []KeyOccurrence, firstOnly bool) {
 	if len(occurrences) == 0 {
 		fmt.Printf("Key \"%s\" not found in %s\n", keyToCheck, inputFile)
+	} else if firstOnly {
+		fmt.Printf("Key \"%s\" found in %s (first occurrence):\n", keyToCheck, inputFile)
+		fmt.Printf("  Line %d: \"%s\"\n", occurrences[0].LineNum, occurrences[0].Value)
 	} else {
 		fmt.Printf("Key \"%s\" found in %s (%d occurrences):\n", keyToCheck, inputFile, len(occurrences))
 
@@ -75,20 +175,84 @@ type KeyOccurrence struct {
 	LineNum int
 }
 
-func findKeyOccurrences(filename, keyToFind string) ([]KeyOccurrence, error) {
-	file, err := os.Open(filename)
+// findKeyOccurrences returns every occurrence of keyToFind. With firstOnly it
+// stops scanning at the first match, which is much faster on large files.
+func findKeyOccurrences(filename, keyToFind string, firstOnly bool) ([]KeyOccurrence, error) {
+	var occurrences []KeyOccurrence
+	err := scanEntries(filename, func(key, value string, lineNum int) bool {
+		if key == keyToFind {
+			occurrences = append(occurrences, KeyOccurrence{
+				Value:   value,
+				LineNum: lineNum,
+			})
+			return !firstOnly
+		}
+		return true
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
+	return occurrences, nil
+}
 
-	var occurrences []KeyOccurrence
+type ValueMatch struct {
+	Key     string
+	Value   string
+	LineNum int
+}
+
+// findValueMatches returns every key-value pair whose value matches pattern
+func findValueMatches(filename string, pattern *regexp.Regexp) ([]ValueMatch, error) {
+	var matches []ValueMatch
+	err := scanEntries(filename, func(key, value string, lineNum int) bool {
+		if pattern.MatchString(value) {
+			matches = append(matches, ValueMatch{
+				Key:     key,
+				Value:   value,
+				LineNum: lineNum,
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// findAllOccurrences returns the occurrences of every key in the file
+func findAllOccurrences(filename string) (map[string][]KeyOccurrence, error) {
+	occurrences := make(map[string][]KeyOccurrence)
+	err := scanEntries(filename, func(key, value string, lineNum int) bool {
+		occurrences[key] = append(occurrences[key], KeyOccurrence{
+			Value:   value,
+			LineNum: lineNum,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return occurrences, nil
+}
 
-	// Regular expression to extract key-value pairs
-	// This pattern matches: "key" = "value";
-	kvPattern := regexp.MustCompile(`"([^"]+)"\s*=\s*"([^"]+)"\s*;`)
+// Regular expression to extract key-value pairs
+// This pattern matches: "key" = "value";
+var kvPattern = regexp.MustCompile(`"([^"]+)"\s*=\s*"([^"]+)"\s*;`)
+
+// scanEntries calls fn for every key-value pair in the file, in file order,
+// skipping blank and comment lines. Scanning stops early when fn returns
+// false.
+func scanEntries(filename string, fn func(key, value string, lineNum int) bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
+	// Values can be far longer than the default 64KB line limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
 	lineNum := 0
 
 	for scanner.Scan() {
@@ -102,22 +266,13 @@ func findKeyOccurrences(filename, keyToFind string) ([]KeyOccurrence, error) {
 		}
 
 		matches := kvPattern.FindStringSubmatch(line)
-		if len(matches) == 3 {
-			key := matches[1]
-			value := matches[2]
-
-			if key == keyToFind {
-				occurrences = append(occurrences, KeyOccurrence{
-					Value:   value,
-					LineNum: lineNum,
-				})
-			}
+		if len(matches) == 3 && !fn(matches[1], matches[2], lineNum) {
+			break
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error scanning file: %w", err)
+		return fmt.Errorf("error scanning file: %w", err)
 	}
-
-	return occurrences, nil
+	return nil
 }
@@ -1,21 +1,54 @@
+//go:build ignore
+
 package main
 
 import (
 	"bufio"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
+// FileStats describes the composition of a .strings file
+type FileStats struct {
+	UniqueKeys     int
+	TotalEntries   int
+	CommentLines   int
+	BlankLines     int
+	MalformedLines int
+}
+
+// countReport is the -count-format json/csv/tsv form of the counts
+type countReport struct {
+	File                string  `json:"file"`
+	TotalEntries        int     `json:"totalEntries"`
+	UniqueKeys          int     `json:"uniqueKeys"`
+	Duplicates          int     `json:"duplicates"`
+	DuplicatePercentage float64 `json:"duplicatePercentage"`
+	CommentLines        int     `json:"commentLines"`
+	BlankLines          int     `json:"blankLines"`
+	MalformedLines      int     `json:"malformedLines"`
+}
+
 // Simple utility to count the number of unique keys in a .strings file
 func main() {
 	// Parse command-line flags
 	var inputFile string
+	var countFormat string
 	flag.StringVar(&inputFile, "f", "Localizable.strings", "Input localization file (default: Localizable.strings)")
+	flag.StringVar(&countFormat, "count-format", "text", "Output format: text, json, csv or tsv")
 	flag.Parse()
 
+	if countFormat != "text" && countFormat != "json" && countFormat != "csv" && countFormat != "tsv" {
+		fmt.Printf("Error: -count-format must be \"text\", \"json\", \"csv\" or \"tsv\"\n")
+		os.Exit(1)
+	}
+
 	// Check if the file exists
 	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
 		fmt.Printf("Error: File %s does not exist\n", inputFile)
@@ -23,16 +56,40 @@ func main() {
 	}
 
 	// Count unique keys
-	keyCount, totalEntries, err := countKeys(inputFile)
+	stats, err := countKeys(inputFile)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	keyCount, totalEntries := stats.UniqueKeys, stats.TotalEntries
+
+	if countFormat != "text" {
+		report := countReport{
+			File:           inputFile,
+			TotalEntries:   totalEntries,
+			UniqueKeys:     keyCount,
+			Duplicates:     totalEntries - keyCount,
+			CommentLines:   stats.CommentLines,
+			BlankLines:     stats.BlankLines,
+			MalformedLines: stats.MalformedLines,
+		}
+		if totalEntries > 0 {
+			report.DuplicatePercentage = float64(report.Duplicates) / float64(totalEntries) * 100
+		}
+		if err := writeCountReport(report, countFormat); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Report results
 	fmt.Printf("File: %s\n", inputFile)
 	fmt.Printf("Total Entries: %d\n", totalEntries)
 	fmt.Printf("Unique Keys: %d\n", keyCount)
+	fmt.Printf("Comment Lines: %d\n", stats.CommentLines)
+	fmt.Printf("Blank Lines: %d\n", stats.BlankLines)
+	fmt.Printf("Malformed Lines: %d\n", stats.MalformedLines)
 
 	if totalEntries > keyCount {
 		duplicates := totalEntries - keyCount
@@ -43,10 +100,41 @@ func main() {
 	}
 }
 
-func countKeys(filename string) (int, int, error) {
+// writeCountReport prints the counts to stdout as JSON, or as a header row
+// and a value row of CSV or TSV
+func writeCountReport(report countReport, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	writer := csv.NewWriter(os.Stdout)
+	if format == "tsv" {
+		writer.Comma = '\t'
+	}
+	writer.Write([]string{"file", "totalEntries", "uniqueKeys", "duplicates", "duplicatePercentage",
+		"commentLines", "blankLines", "malformedLines"})
+	writer.Write([]string{
+		report.File,
+		strconv.Itoa(report.TotalEntries),
+		strconv.Itoa(report.UniqueKeys),
+		strconv.Itoa(report.Duplicates),
+		strconv.FormatFloat(report.DuplicatePercentage, 'f', 1, 64),
+		strconv.Itoa(report.CommentLines),
+		strconv.Itoa(report.BlankLines),
+		strconv.Itoa(report.MalformedLines),
+	})
+	writer.Flush()
+	return writer.Error()
+}
+
+func countKeys(filename string) (FileStats, error) {
+	var stats FileStats
+
 	file, err := os.Open(filename)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to open file: %w", err)
+		return stats, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
@@ -58,14 +146,34 @@ func countKeys(filename string) (int, int, error) {
 	kvPattern := regexp.MustCompile(`"([^"]+)"\s*=\s*"([^"]+)"\s*;`)
 
 	scanner := bufio.NewScanner(file)
-	totalEntries := 0
+	// Values can be far longer than the default 64KB line limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	inBlockComment := false
 
 	for scanner.Scan() {
 		line := scanner.Text()
-
-		// Skip comment lines or empty lines
 		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "//") {
+
+		// Lines inside a /* ... */ block are comments until the block closes
+		if inBlockComment {
+			stats.CommentLines++
+			inBlockComment = !strings.Contains(trimmedLine, "*/")
+			continue
+		}
+
+		if trimmedLine == "" {
+			stats.BlankLines++
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "//") {
+			stats.CommentLines++
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "/*") {
+			stats.CommentLines++
+			inBlockComment = !strings.Contains(trimmedLine[2:], "*/")
 			continue
 		}
 
@@ -73,13 +181,17 @@ func countKeys(filename string) (int, int, error) {
 		if len(matches) == 3 {
 			key := matches[1]
 			uniqueKeys[key] = true
-			totalEntries++
+			stats.TotalEntries++
+		} else {
+			// Not a comment, blank line or key-value pair
+			stats.MalformedLines++
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return 0, 0, fmt.Errorf("error scanning file: %w", err)
+		return stats, fmt.Errorf("error scanning file: %w", err)
 	}
 
-	return len(uniqueKeys), totalEntries, nil
+	stats.UniqueKeys = len(uniqueKeys)
+	return stats, nil
 }
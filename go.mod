@@ -1,3 +1,8 @@
 module github.com/localization-analyzer
 
-go 1.21 
\ No newline at end of file
+go 1.21
+
+require (
+	github.com/rivo/uniseg v0.4.7
+	golang.org/x/text v0.14.0
+)
@@ -1,279 +1,5844 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime"
+	"runtime/pprof"
 	"sort"
+	"strconv"
 	"strings"
-)
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
-type KeyValue struct {
-	Key     string
-	Value   string
-	LineNum int
-}
+	"github.com/localization-analyzer/analyzer"
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
 
 func main() {
 	// Parse command-line flags
 	var outputFile string
 	var inputFile string
 	var cleanFile string
+	var compareFile string
+	var compareBranch string
+	var checkMarkup bool
+	var markupTags string
+	var onlyKeys stringListFlag
+	var ignoreFile string
+	var sarifFile string
+	var maxLen int
+	var showStats bool
+	var statsPerValue bool
+	var maxGraphemes int
+	var exportFile string
+	var splitDir string
+	var sortOutput bool
+	var includeCommented bool
+	var coverageFile string
+	var jsonOutput bool
+	var quiet bool
+	var failOnDuplicates bool
+	var failOnConflicts bool
+	var maxDuplicates int
+	var inputEncoding string
+	var dirPath string
+	var excludePatterns stringListFlag
+	var checkRefs bool
+	var summaryLine bool
+	var summaryOnly bool
+	var separator string
+	var sortBy string
+	var cleanInteractive bool
+	var checkBrackets bool
+	var dedupeInPlace bool
+	var checkControl bool
+	var stripControl bool
+	var stripOrphanedComments bool
+	var stripComments bool
+	var keepBlankLines bool
+	var lineEndings string
+	var outputDir string
+	var allowControl string
+	var ignoreWhitespace bool
+	var valueDiff string
+	var allowReorder bool
+	var keysOnly bool
+	var goGenFile string
+	var goPackage string
+	var dupHistogram bool
+	var metadataPrefix string
+	var followIncludes bool
+	var renames stringListFlag
+	var valueReplacements stringListFlag
+	var regexReplacements stringListFlag
+	var normalizeKeys string
+	var checkNewlines bool
+	var jsonLines bool
+	var checkLineCounts bool
+	var profile bool
+	var checkKeyChars bool
+	var topN int
+	var sinceRef string
+	var strict bool
+	var cacheFile string
+	var checkCase bool
+	var quoteStyle string
+	var inputFormat string
+	var orderBaseFile string
+	var infoPlist bool
+	var infoPlistRequired stringListFlag
+	var syncBaseFile string
+	var adjacency int
+	var contextLines int
+	var spellcheckFile string
+	var spellcheckIgnoreFile string
+	var explain bool
+	var tee bool
+	var namespacePrefix string
+	var markdownFile string
+	var countByLanguage bool
+	var checkTrimmedKeys bool
+	var checkKeyWhitespace bool
+	var checkPlaceholderOnly bool
+	var checkEscapes bool
+	var maxRepeat int
+	var checkOrphanedComments bool
+	var maxLineSize int
+	var dryRun bool
+	var compareAllGlob string
+	var checkUntranslated bool
+	var checkPlaceholderTypes bool
+	var failOnPlaceholderTypes bool
+	var checkCaseCollisions bool
+	var repairFile string
+	var archivePath string
+	var lintCommand string
+	var lintJobs int
+	var identicalAllowFile string
+	var baseLanguage string
+	var caseSeparator string
+	var cpuProfileFile string
+	var printSchema bool
 	var verbose bool
 
 	flag.StringVar(&outputFile, "o", "", "Output file for results (optional)")
-	flag.StringVar(&inputFile, "f", "Localizable.strings", "Input localization file (default: Localizable.strings)")
+	flag.StringVar(&inputFile, "f", "Localizable.strings", "Input localization file, or - to read from stdin (default: Localizable.strings)")
 	flag.StringVar(&cleanFile, "clean", "", "Create a cleaned version (without duplicates) at the specified path")
+	flag.StringVar(&compareAllGlob, "compare-all", "", "Compare the input file against every translation file matching this glob and print a key x language presence matrix")
+	flag.StringVar(&compareFile, "compare", "", "Compare the input file against another localization file and report added, removed and changed keys")
+	flag.StringVar(&compareBranch, "compare-branch", "", "Compare the input file as committed at this git ref (e.g. a release tag) with the working copy and report added, removed and changed keys")
+	flag.BoolVar(&checkMarkup, "markup", false, "Check that markup tags inside values are balanced")
+	flag.StringVar(&markupTags, "markup-tags", "b,i,u,a,em,strong,span,font", "Comma-separated list of tags checked by -markup")
+	flag.Var(&onlyKeys, "only", "Only report duplicate information for this key (can be repeated)")
+	flag.StringVar(&ignoreFile, "ignore", "", "File with keys (one per line) to exclude from duplicate reporting")
+	flag.StringVar(&markdownFile, "md", "", "Write duplicates and conflicts as a Markdown report (e.g. for a PR comment) to the specified file")
+	flag.StringVar(&sarifFile, "sarif", "", "Write duplicates, conflicts and malformed lines as a SARIF 2.1.0 report to the specified file")
+	flag.BoolVar(&showStats, "stats", false, "Print byte, character (rune) and grapheme cluster totals for all values")
+	flag.BoolVar(&statsPerValue, "stats-per-value", false, "With -stats, also list every value whose grapheme count differs from its rune count")
+	flag.IntVar(&maxGraphemes, "max-graphemes", 0, "With -stats, flag values longer than this many grapheme clusters (user-perceived characters)")
+	flag.IntVar(&maxLen, "max-len", 0, "Report values longer than this many characters and exit non-zero (0 disables the check)")
+	flag.StringVar(&splitDir, "split", "", "Write one .strings file per key namespace (the part of the key before the first \".\") to this directory")
+	flag.StringVar(&exportFile, "export", "", "Export unique entries to a .json or .csv file (in file order)")
+	flag.BoolVar(&sortOutput, "sort-output", false, "Sort exported entries alphabetically by key instead of file order")
+	flag.BoolVar(&includeCommented, "include-commented", false, "Also extract keys from // comments and report commented keys that are still active")
+	flag.StringVar(&coverageFile, "coverage", "", "Report how much of the given base file is translated in the input file")
+	flag.BoolVar(&jsonOutput, "json", false, "Write the report as JSON")
+	flag.BoolVar(&quiet, "quiet", false, "Print nothing on success and one line per problem otherwise")
+	flag.BoolVar(&failOnDuplicates, "fail-on-duplicates", false, "Exit with a non-zero status when duplicate keys are found")
+	flag.BoolVar(&failOnConflicts, "fail-on-conflicts", false, "Exit with a non-zero status when duplicate keys with different values are found")
+	flag.IntVar(&maxDuplicates, "max-duplicates", -1, "Exit with a non-zero status only when there are more than this many duplicate entries in total (-1 disables the budget)")
+	flag.StringVar(&inputEncoding, "encoding", "", "Input encoding, e.g. utf-8, latin1 or windows-1252 (default: UTF-8, falling back to Windows-1252 for invalid UTF-8)")
+	flag.StringVar(&archivePath, "archive", "", "Analyze every .strings file inside a .zip, .tar or .tar.gz archive without extracting it")
+	flag.StringVar(&dirPath, "dir", "", "Analyze every .strings file under this directory")
+	flag.Var(&excludePatterns, "exclude", "Glob of paths to skip when scanning with -dir, e.g. Pods or **/Generated/** (can be repeated)")
+	flag.BoolVar(&checkRefs, "check-refs", false, "Check %{other_key} references in values for unknown keys and reference cycles")
+	flag.BoolVar(&summaryOnly, "summary-only", false, "Print only the totals (entries, unique keys, duplicates, conflicts) instead of listing every duplicate key")
+	flag.BoolVar(&summaryLine, "summary-line", false, "Print only a single entries=N unique=N duplicates=N conflicts=N line")
+	flag.StringVar(&lintCommand, "lint-cmd", "", "Run this command for every unique entry with the key and value as its last two arguments; a non-zero exit is a violation")
+	flag.IntVar(&lintJobs, "lint-jobs", runtime.NumCPU(), "Number of -lint-cmd commands to run at the same time")
+	flag.StringVar(&repairFile, "repair", "", "Write a copy of the input with common malformations fixed to the specified file")
+	flag.BoolVar(&dryRun, "dry-run", false, "With -clean or -dedupe-in-place, only print how many entries cleaning would remove")
+	flag.IntVar(&maxLineSize, "max-line", analyzer.DefaultMaxLineSize, "Longest line accepted, in bytes")
+	flag.BoolVar(&checkOrphanedComments, "check-orphaned-comments", false, "Report comment blocks that aren't followed by a key-value line")
+	flag.IntVar(&maxRepeat, "max-repeat", 0, "Report values with a run of the same character longer than this, such as \"Loadinggggg\" (0 disables the check)")
+	flag.BoolVar(&checkEscapes, "check-escapes", false, "Report backslash escapes in values that iOS doesn't interpret, such as \\x or \\q")
+	flag.BoolVar(&checkPlaceholderOnly, "check-placeholder-only", false, "Report values that consist only of placeholders and whitespace, such as \"%@ %@\"")
+	flag.BoolVar(&checkKeyWhitespace, "check-key-whitespace", false, "Report keys that collide once whitespace inside them is replaced by _, such as \"home title\" and \"home_title\"")
+	flag.BoolVar(&checkTrimmedKeys, "check-trimmed-keys", false, "Report keys that only differ by leading or trailing separators such as _home and home.")
+	flag.StringVar(&namespacePrefix, "namespace-prefix", "", "Report keys that collide with another key once this namespace prefix (a regular expression, e.g. '^[^.]+\\.') is stripped")
+	flag.BoolVar(&tee, "tee", false, "With -o, also echo the results to the terminal")
+	flag.BoolVar(&explain, "explain", false, "List lines that weren't parsed as key-value pairs with a best-guess reason")
+	flag.IntVar(&adjacency, "adjacency", 3, "Duplicate groups whose occurrences all lie within this many lines are marked adjacent, others scattered")
+	flag.IntVar(&contextLines, "context", 0, "Print this many lines of surrounding context for each duplicate occurrence")
+	flag.StringVar(&syncBaseFile, "sync-new", "", "Append stubs for keys of the specified base file that the input file is missing")
+	flag.BoolVar(&infoPlist, "infoplist", false, "Check an InfoPlist.strings file: report missing required keys and keys that aren't known Info.plist keys")
+	flag.Var(&infoPlistRequired, "infoplist-require", "With -infoplist, a key the file must define (can be repeated; default CFBundleDisplayName)")
+	flag.StringVar(&orderBaseFile, "check-order", "", "Check that the input file keeps its keys in the same order as the specified base file")
+	flag.StringVar(&inputFormat, "format", "", "Input format: strings or po (default: po for .po/.pot files, strings otherwise)")
+	flag.StringVar(&quoteStyle, "quote", "double", "Quote style of keys and values: double, single ('key' = 'value';) or any")
+	flag.StringVar(&separator, "separator", "=", "Character separating keys from values, e.g. : for \"key\" : \"value\";")
+	flag.StringVar(&sortBy, "sort-by", "key", "Order of the duplicate report: key (alphabetical) or count (most occurrences first)")
+	flag.BoolVar(&cleanInteractive, "clean-interactive", false, "With -clean, ask which value to keep for each conflicting duplicate")
+	flag.BoolVar(&checkBrackets, "brackets", false, "Check that (), [] and {} pairs inside values are balanced")
+	flag.BoolVar(&dedupeInPlace, "dedupe-in-place", false, "Remove duplicates from the input file itself, saving the original as <file>.bak")
+	flag.BoolVar(&checkControl, "control-chars", false, "Report control characters (tabs, vertical tabs, ...) inside values")
+	flag.BoolVar(&stripControl, "strip-control", false, "Remove control characters from values in the cleaned file")
+	flag.BoolVar(&stripComments, "strip-comments", false, "Leave all comment lines, and blank lines, out of the cleaned file")
+	flag.BoolVar(&keepBlankLines, "keep-blank-lines", false, "With -strip-comments, keep blank lines in the cleaned file")
+	flag.BoolVar(&stripOrphanedComments, "strip-orphaned-comments", false, "Leave comments that aren't followed by a key out of the cleaned file")
+	flag.StringVar(&outputDir, "output-dir", "", "With -dir or -archive, write a cleaned copy of every file to this directory, keeping the relative directory structure")
+	flag.StringVar(&lineEndings, "line-endings", "lf", "Line endings of the cleaned file: lf or crlf")
+	flag.StringVar(&allowControl, "allow-control", "", "Comma-separated hex code points of control characters to allow, e.g. 09 for tab")
+	flag.StringVar(&valueDiff, "diff", "", "With -compare or -compare-branch, show how changed values differ, by \"word\" or \"char\"")
+	flag.BoolVar(&keysOnly, "keys-only", false, "With -compare or -compare-branch, only list the added and removed keys, sorted, without changed values")
+	flag.BoolVar(&allowReorder, "allow-reorder", false, "With -compare or -compare-branch, report values that only reorder positional placeholders (%1$@, %2$@) separately instead of as changed")
+	flag.BoolVar(&ignoreWhitespace, "ignore-whitespace", false, "With -compare or -compare-branch, ignore leading, trailing and repeated whitespace in values")
+	flag.StringVar(&goGenFile, "gogen", "", "Write the unique entries as a Go source file declaring var Strings = map[string]string{...}")
+	flag.StringVar(&goPackage, "gopkg", "localization", "Package name used by -gogen")
+	flag.StringVar(&metadataPrefix, "metadata-prefix", "", "Read \"Name: value\" metadata such as \"// Translated-By: Ana\" from comment lines starting with this prefix and report it")
+	flag.BoolVar(&dupHistogram, "dup-histogram", false, "Show how many keys appear exactly 2 times, 3 times, and so on")
+	flag.BoolVar(&followIncludes, "follow-includes", false, "Merge entries from #include \"other.strings\" directives into the analysis")
+	flag.StringVar(&normalizeKeys, "normalize-keys", "", "Rewrite keys in the cleaned file to snake_case, camelCase or dot.case, reporting keys that would collide")
+	flag.Var(&valueReplacements, "replace-value", "Replace a substring in every value of the cleaned file, as old=new (can be repeated, applied in order)")
+	flag.Var(&regexReplacements, "replace-regex", "Replace regular expression matches in every value of the cleaned file, as pattern=replacement with $1 for groups (can be repeated, applied after -replace-value)")
+	flag.Var(&renames, "rename", "Rename a key in the cleaned file, as old=new (can be repeated, applied in order)")
+	flag.BoolVar(&checkNewlines, "check-newlines", false, "Report values that span several lines (literal line breaks instead of \\n escapes)")
+	flag.BoolVar(&jsonLines, "jsonl", false, "Stream one JSON object per entry as it is parsed (JSON Lines)")
+	flag.BoolVar(&checkLineCounts, "check-lines", false, "With -compare or -compare-branch, report keys whose values have a different number of \\n-separated lines")
+	flag.BoolVar(&checkCase, "check-case", false, "Report the capitalization style of values and flag outliers within each key prefix")
+	flag.StringVar(&caseSeparator, "case-separator", ".", "Separator between a key's prefix and its last part, used to group keys for -check-case")
+	flag.StringVar(&spellcheckFile, "spellcheck", "", "Report words in values that are missing from this dictionary file (one word per line)")
+	flag.StringVar(&spellcheckIgnoreFile, "spellcheck-ignore", "", "With -spellcheck, file of additional words to accept (one per line)")
+	flag.BoolVar(&countByLanguage, "count-by-language", false, "With -dir, print one table row per language (from xx.lproj directories) instead of per-file reports")
+	flag.BoolVar(&checkCaseCollisions, "check-case-collisions", false, "With -dir or -follow-includes, warn about keys in different files that differ only in case, such as \"OK\" and \"ok\"")
+	flag.BoolVar(&checkPlaceholderTypes, "check-placeholder-types", false, "With -dir, report keys whose placeholder types differ between languages, such as %d in one and %@ in another")
+	flag.BoolVar(&failOnPlaceholderTypes, "fail-on-placeholder-types", false, "With -check-placeholder-types, exit with a non-zero status when placeholder types differ between languages")
+	flag.BoolVar(&checkUntranslated, "check-untranslated", false, "With -dir, report keys whose value is identical to the base language in every other language")
+	flag.StringVar(&identicalAllowFile, "identical-allow", "", "File listing keys that are intentionally identical in every language (e.g. brand names), one per line")
+	flag.StringVar(&baseLanguage, "base-lang", "en", "Language that -count-by-language measures translation progress against")
+	flag.StringVar(&cacheFile, "cache", "", "With -dir, reuse parse results for unchanged files from this cache file")
+	flag.BoolVar(&strict, "strict", false, "Exit with status 1 when errors such as empty keys are found")
+	flag.StringVar(&sinceRef, "since", "", "Only report duplicates involving lines added since the given git ref")
+	flag.IntVar(&topN, "top", 0, "Only show the first n duplicate keys in the report (0 = all; ignored with -json and -o)")
+	flag.BoolVar(&checkKeyChars, "check-key-chars", false, "Report keys containing a quote, equals sign, semicolon or the separator")
+	flag.BoolVar(&profile, "profile", false, "Print time spent parsing, reporting and cleaning")
+	flag.StringVar(&cpuProfileFile, "cpuprofile", "", "Write a pprof CPU profile to the specified file")
 	flag.BoolVar(&verbose, "v", false, "Verbose output - include details in terminal output")
+
+	// -print-schema is for integrators and deliberately left out of -help,
+	// which prints a copy of the flags without it
+	flag.BoolVar(&printSchema, "print-schema", false, "Print the JSON Schema of the -json report and exit")
+	listedFlags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name != "print-schema" {
+			listedFlags.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		listedFlags.SetOutput(flag.CommandLine.Output())
+		listedFlags.PrintDefaults()
+	}
+
+	// Team defaults from the working directory, read before the command line
+	// so that a broken config file is reported even when flags are wrong
+	config, err := loadConfigFile(configFileName)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		os.Exit(1)
+	}
+
 	flag.Parse()
 
-	// Set up output
-	var output *os.File
-	var err error
-	if outputFile != "" {
-		output, err = os.Create(outputFile)
-		if err != nil {
-			fmt.Printf("Error creating output file: %v\n", err)
+	if printSchema {
+		if err := writeJSON(os.Stdout, jsonReportSchema()); err != nil {
+			fmt.Printf("Error writing schema: %v\n", err)
 			os.Exit(1)
 		}
-		defer output.Close()
-	} else {
-		output = os.Stdout
+		return
 	}
 
-	// Analyze the file
-	duplicateKeys, uniqueEntries, rawLines, err := analyzeLocalizationFile(inputFile)
+	if err := applyConfig(config); err != nil {
+		fmt.Printf("Error in config file %s: %v\n", configFileName, err)
+		os.Exit(1)
+	}
+
+	if sortBy != "key" && sortBy != "count" {
+		fmt.Printf("Error: -sort-by must be \"key\" or \"count\"\n")
+		os.Exit(1)
+	}
+
+	if cleanInteractive && ((cleanFile == "" && !dedupeInPlace) || inputFile == "-") {
+		fmt.Printf("Error: -clean-interactive requires -clean or -dedupe-in-place and an input file other than stdin\n")
+		os.Exit(1)
+	}
+
+	if normalizeKeys != "" {
+		if normalizeKeys != "snake_case" && normalizeKeys != "camelCase" && normalizeKeys != "dot.case" {
+			fmt.Printf("Error: -normalize-keys must be \"snake_case\", \"camelCase\" or \"dot.case\"\n")
+			os.Exit(1)
+		}
+		if cleanFile == "" && !dedupeInPlace {
+			fmt.Printf("Error: -normalize-keys requires -clean or -dedupe-in-place\n")
+			os.Exit(1)
+		}
+		if len(renames) > 0 {
+			fmt.Printf("Error: -normalize-keys can't be combined with -rename\n")
+			os.Exit(1)
+		}
+	}
+
+	if len(renames) > 0 && cleanFile == "" && !dedupeInPlace {
+		fmt.Printf("Error: -rename requires -clean or -dedupe-in-place\n")
+		os.Exit(1)
+	}
+
+	if len(valueReplacements)+len(regexReplacements) > 0 && cleanFile == "" && !dedupeInPlace {
+		fmt.Printf("Error: -replace-value and -replace-regex require -clean or -dedupe-in-place\n")
+		os.Exit(1)
+	}
+
+	if outputDir != "" && dirPath == "" && archivePath == "" {
+		fmt.Printf("Error: -output-dir requires -dir or -archive\n")
+		os.Exit(1)
+	}
+
+	if stripOrphanedComments && cleanFile == "" && !dedupeInPlace && outputDir == "" {
+		fmt.Printf("Error: -strip-orphaned-comments requires -clean, -dedupe-in-place or -output-dir\n")
+		os.Exit(1)
+	}
+
+	if stripComments && cleanFile == "" && outputDir == "" {
+		fmt.Printf("Error: -strip-comments requires -clean or -output-dir\n")
+		os.Exit(1)
+	}
+	if keepBlankLines && !stripComments {
+		fmt.Printf("Error: -keep-blank-lines requires -strip-comments\n")
+		os.Exit(1)
+	}
+
+	if dryRun && cleanFile == "" && !dedupeInPlace {
+		fmt.Printf("Error: -dry-run requires -clean or -dedupe-in-place\n")
+		os.Exit(1)
+	}
+	if dedupeInPlace && (cleanFile != "" || inputFile == "-") {
+		fmt.Printf("Error: -dedupe-in-place can't be combined with -clean or used with stdin input\n")
+		os.Exit(1)
+	}
+
+	allowedControl, err := parseCodePoints(allowControl)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Report duplicate keys
-	if len(duplicateKeys) > 0 {
-		fmt.Fprintf(output, "Duplicate keys found: %d\n", len(duplicateKeys))
-		fmt.Fprintf(output, "====================\n")
+	if lineEndings != "lf" && lineEndings != "crlf" {
+		fmt.Printf("Error: -line-endings must be \"lf\" or \"crlf\"\n")
+		os.Exit(1)
+	}
 
-		// Sort keys for consistent output
-		var keys []string
-		for key := range duplicateKeys {
-			keys = append(keys, key)
+	if (statsPerValue || maxGraphemes > 0) && !showStats {
+		fmt.Printf("Error: -stats-per-value and -max-graphemes require -stats\n")
+		os.Exit(1)
+	}
+
+	if valueDiff != "" && valueDiff != "word" && valueDiff != "char" {
+		fmt.Printf("Error: -diff must be \"word\" or \"char\"\n")
+		os.Exit(1)
+	}
+
+	if checkCaseCollisions && dirPath == "" && archivePath == "" && !followIncludes {
+		fmt.Printf("Error: -check-case-collisions requires -dir, -archive or -follow-includes\n")
+		os.Exit(1)
+	}
+
+	if keysOnly && compareFile == "" && compareBranch == "" {
+		fmt.Printf("Error: -keys-only requires -compare or -compare-branch\n")
+		os.Exit(1)
+	}
+
+	if compareBranch != "" && (compareFile != "" || inputFile == "-") {
+		fmt.Printf("Error: -compare-branch can't be combined with -compare or used with stdin\n")
+		os.Exit(1)
+	}
+
+	if inputFormat != "" && inputFormat != "strings" && inputFormat != "po" {
+		fmt.Printf("Error: -format must be \"strings\" or \"po\"\n")
+		os.Exit(1)
+	}
+
+	if topN < 0 {
+		fmt.Printf("Error: -top must not be negative\n")
+		os.Exit(1)
+	}
+
+	// A report written to a file is kept complete
+	if outputFile != "" {
+		topN = 0
+	}
+
+	// Start CPU profiling if requested
+	if cpuProfileFile != "" {
+		profileOutput, err := os.Create(cpuProfileFile)
+		if err != nil {
+			fmt.Printf("Error creating CPU profile: %v\n", err)
+			os.Exit(1)
 		}
-		sort.Strings(keys)
+		defer profileOutput.Close()
 
-		for _, key := range keys {
-			entries := duplicateKeys[key]
-			fmt.Fprintf(output, "Key: \"%s\" appears %d times:\n", key, len(entries))
-
-			// Are all values the same?
-			allSame := true
-			firstValue := entries[0].Value
-			for _, entry := range entries[1:] {
-				if entry.Value != firstValue {
-					allSame = false
-					break
-				}
-			}
+		if err := pprof.StartCPUProfile(profileOutput); err != nil {
+			fmt.Printf("Error starting CPU profile: %v\n", err)
+			os.Exit(1)
+		}
+		defer pprof.StopCPUProfile()
+	}
 
-			if allSame {
-				fmt.Fprintf(output, "  All entries have the same value: \"%s\"\n", firstValue)
-			} else {
-				fmt.Fprintf(output, "  WARNING: Key has different values (localization conflict)!\n")
-			}
+	// Set up output
+	var output io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			fmt.Printf("Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
 
-			fmt.Fprintf(output, "  Found at lines:\n")
-			for _, entry := range entries {
-				if !allSame {
-					fmt.Fprintf(output, "    Line %d: \"%s\"\n", entry.LineNum, entry.Value)
-				} else {
-					fmt.Fprintf(output, "    Line %d\n", entry.LineNum)
-				}
-			}
-			fmt.Fprintf(output, "\n")
+		output = file
+		if tee {
+			output = io.MultiWriter(file, os.Stdout)
 		}
-	} else {
-		fmt.Fprintf(output, "No duplicate keys found.\n")
+	} else if tee {
+		fmt.Printf("Error: -tee requires -o\n")
+		os.Exit(1)
 	}
 
-	// Create a cleaned file if requested
-	if cleanFile != "" {
-		// Make sure we're not overwriting the input file
-		if filepath.Clean(cleanFile) == filepath.Clean(inputFile) {
-			// Suggest a different name based on the input file
-			suggestedName := createUniqueFilename(inputFile)
-			fmt.Printf("Error: Clean file cannot be the same as input file.\n")
-			fmt.Printf("Please use a different filename, e.g., '%s'\n", suggestedName)
+	parseOptions := analyzer.ParseOptions{
+		IncludeCommented: includeCommented,
+		Encoding:         inputEncoding,
+		Separator:        separator,
+		FollowIncludes:   followIncludes,
+		Quote:            quoteStyle,
+		MaxLineSize:      maxLineSize,
+		Format:           inputFormat,
+	}
+
+	// Stream entries as JSON Lines without building a full result
+	if jsonLines {
+		if err := streamJSONLines(inputFile, output, parseOptions); err != nil {
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
+		return
+	}
 
-		err := createCleanFile(cleanFile, uniqueEntries, rawLines)
+	// Keys whose duplicates are intentional, left out of the report in
+	// both single-file and directory mode
+	var ignoredKeys map[string]bool
+	if ignoreFile != "" {
+		ignoredKeys, err = readKeyList(ignoreFile)
 		if err != nil {
-			fmt.Printf("Error creating clean file: %v\n", err)
+			fmt.Printf("Error reading ignore list: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Created cleaned file at %s\n", cleanFile)
-		fmt.Printf("Removed %d duplicate key entries.\n", countDuplicates(duplicateKeys))
 	}
 
-	// Print summary if outputting to file or in verbose mode
-	if outputFile != "" || verbose {
-		if len(duplicateKeys) > 0 {
-			fmt.Printf("Analysis complete. Found %d duplicate keys with %d total duplicated entries.\n",
-				len(duplicateKeys), countDuplicates(duplicateKeys))
+	// Analyze every file in a directory or archive instead of a single file
+	if dirPath != "" || archivePath != "" {
+		var files []string
+		var skipped int
+		var archived map[string][]byte
+		if archivePath != "" {
+			archived, skipped, err = readArchiveStrings(archivePath, excludePatterns)
+			if err != nil {
+				fmt.Printf("Error reading archive: %v\n", err)
+				os.Exit(1)
+			}
+			for path := range archived {
+				files = append(files, path)
+			}
+			sort.Strings(files)
 
-			if outputFile != "" {
-				fmt.Printf("Results written to %s\n", outputFile)
+			// Included files can't be resolved inside an archive
+			parseOptions.FollowIncludes = false
+		} else {
+			files, skipped, err = findStringsFiles(dirPath, excludePatterns)
+			if err != nil {
+				fmt.Printf("Error scanning directory: %v\n", err)
+				os.Exit(1)
 			}
+		}
 
-			if cleanFile == "" {
-				fmt.Println("Use -clean=filename.strings to create a cleaned version with duplicates removed.")
+		// Results can't be reused when they depend on #include files,
+		// since a change to an included file wouldn't be noticed. The
+		// language table (which checks skipped lines for empty values),
+		// -context and -output-dir need the raw lines, which the cache
+		// doesn't keep.
+		var cache *scanCache
+		if cacheFile != "" && archived == nil && !followIncludes && !countByLanguage && contextLines == 0 && outputDir == "" {
+			cache, err = loadScanCache(cacheFile, parseOptions)
+			if err != nil {
+				fmt.Printf("Warning: ignoring cache: %v\n", err)
+				cache = newScanCache(parseOptions)
 			}
-		} else if verbose {
-			fmt.Println("No duplicate keys found.")
 		}
-	}
-}
 
-func createUniqueFilename(filename string) string {
-	dir := filepath.Dir(filename)
-	base := filepath.Base(filename)
-	ext := filepath.Ext(filename)
-	nameWithoutExt := strings.TrimSuffix(base, ext)
+		languages := make(map[string]*languageStats)
+		languageValues := make(map[string]map[string]analyzer.KeyValue)
+		languageEntries := make(map[string][]analyzer.KeyValue)
 
-	return filepath.Join(dir, nameWithoutExt+"-cleaned"+ext)
-}
+		filesWithDuplicates := 0
+		filesWithConflicts := 0
+		totalDuplicates := 0
+		var totals summaryTotals
+		for _, path := range files {
+			var fileResult *analyzer.Result
+			if archived != nil {
+				fileResult, err = analyzer.Parse(path, bytes.NewReader(archived[path]), parseOptions)
+			} else if cache != nil {
+				fileResult, err = cache.analyze(path, parseOptions)
+			} else {
+				fileResult, err = analyzeLocalizationFile(path, parseOptions)
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
 
-func countDuplicates(duplicateKeys map[string][]KeyValue) int {
-	count := 0
-	for _, entries := range duplicateKeys {
-		count += len(entries) - 1 // Count all occurrences beyond the first one
-	}
-	return count
-}
+			// Write the cleaned copy under the output directory
+			if outputDir != "" {
+				relPath := path
+				if archived == nil {
+					if relPath, err = filepath.Rel(dirPath, path); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						os.Exit(1)
+					}
+				}
+				target, err := outputDirPath(outputDir, relPath)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+				if archived == nil && filepath.Clean(target) == filepath.Clean(path) {
+					fmt.Printf("Error: -output-dir would overwrite %s; use a different directory\n", path)
+					os.Exit(1)
+				}
+				removed, err := createCleanFile(target, fileResult, cleanOptions{
+					StripControl:   stripControl,
+					AllowedControl: allowedControl,
+					CRLF:           lineEndings == "crlf",
 
-func createCleanFile(filename string, uniqueEntries map[string]KeyValue, rawLines []string) error {
-	// Create the directory if it doesn't exist
-	dir := filepath.Dir(filename)
-	if dir != "." && dir != "" {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
-		}
-	}
+					StripOrphanedComments: stripOrphanedComments,
+					StripComments:         stripComments,
+					KeepBlankLines:        keepBlankLines,
+				})
+				if err != nil {
+					fmt.Printf("Error creating clean file: %v\n", err)
+					os.Exit(1)
+				}
+				if !quiet {
+					fmt.Printf("Wrote %s (removed %d duplicate entries)\n", target, removed)
+				}
+			}
 
-	cleanFile, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create clean file: %w", err)
-	}
-	defer cleanFile.Close()
+			// Like -clean, the cleaned copy still drops ignored duplicates;
+			// only the counts and reports below leave them out
+			fileResult.DuplicateKeys = dropIgnoredKeys(fileResult.DuplicateKeys, ignoredKeys)
+			if len(fileResult.DuplicateKeys) > 0 {
+				filesWithDuplicates++
+			}
+			totalDuplicates += countDuplicates(fileResult.DuplicateKeys)
+			totals.add(fileResult, fileResult.DuplicateKeys)
+			if countConflicts(fileResult.DuplicateKeys) > 0 {
+				filesWithConflicts++
+			}
 
-	// First, write all non-key-value lines (comments, empty lines)
-	// and the first occurrence of each key
-	writtenKeys := make(map[string]bool)
+			if checkCaseCollisions {
+				language := languageForPath(path)
+				for _, key := range fileResult.KeysInOrder() {
+					entry := fileResult.UniqueEntries[key]
+					entry.File = path
+					languageEntries[language] = append(languageEntries[language], entry)
+				}
+			}
 
-	for _, line := range rawLines {
-		trimmedLine := strings.TrimSpace(line)
+			if checkUntranslated || checkPlaceholderTypes {
+				language := languageForPath(path)
+				if languageValues[language] == nil {
+					languageValues[language] = make(map[string]analyzer.KeyValue)
+				}
+				name := filepath.Base(path)
+				for key, entry := range fileResult.UniqueEntries {
+					entry.File = path
+					languageValues[language][name+"\x00"+key] = entry
+				}
+			}
 
-		// Write comments and empty lines as-is
-		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "//") {
-			fmt.Fprintln(cleanFile, line)
-			continue
+			if countByLanguage {
+				language := languageForPath(path)
+				if languages[language] == nil {
+					languages[language] = &languageStats{Language: language, keys: make(map[string]bool)}
+				}
+				languages[language].add(path, fileResult, separator, quoteStyle)
+				continue
+			}
+
+			if quiet {
+				printQuietDuplicates(output, path, fileResult.DuplicateKeys, sortBy)
+				continue
+			}
+			if summaryOnly {
+				continue
+			}
+			fmt.Fprintf(output, "File: %s\n", path)
+			printDuplicateReport(output, fileResult.DuplicateKeys, sortBy, topN, adjacency, fileResult.RawLines, contextLines)
+			if len(fileResult.DuplicateKeys) == 0 {
+				fmt.Fprintf(output, "\n")
+			}
 		}
 
-		// Extract key if this is a key-value line
-		kvPattern := regexp.MustCompile(`"([^"]+)"\s*=\s*"([^"]+)"\s*;`)
-		matches := kvPattern.FindStringSubmatch(line)
+		if cache != nil {
+			if err := cache.save(cacheFile); err != nil {
+				fmt.Printf("Error writing cache: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
-		if len(matches) == 3 {
-			key := matches[1]
+		if countByLanguage {
+			printLanguageTable(output, languages, baseLanguage)
+		}
 
-			// If we haven't written this key yet, write it
-			if !writtenKeys[key] {
-				fmt.Fprintln(cleanFile, line)
-				writtenKeys[key] = true
+		if checkUntranslated {
+			allowed := make(map[string]bool)
+			if identicalAllowFile != "" {
+				allowed, err = readKeyList(identicalAllowFile)
+				if err != nil {
+					fmt.Printf("Error reading allow list: %v\n", err)
+					os.Exit(1)
+				}
 			}
-			// Otherwise, skip this duplicate
-		} else {
-			// Write non-matching lines (not key-value format) as-is
-			fmt.Fprintln(cleanFile, line)
+			reportIdenticalToBase(output, languageValues, baseLanguage, allowed, quiet)
 		}
-	}
 
-	return nil
-}
+		placeholderTypeMismatches := 0
+		if checkPlaceholderTypes {
+			placeholderTypeMismatches = reportPlaceholderTypeMismatches(output, languageValues, quiet)
+		}
 
-func fileExists(filename string) bool {
-	_, err := os.Stat(filename)
-	return err == nil
-}
+		if checkCaseCollisions {
+			// Only files of the same language end up in the same bundle
+			var languageNames []string
+			for language := range languageEntries {
+				languageNames = append(languageNames, language)
+			}
+			sort.Strings(languageNames)
 
-func analyzeLocalizationFile(filename string) (map[string][]KeyValue, map[string]KeyValue, []string, error) {
-	file, err := os.Open(filename)
+			var collisions [][]analyzer.KeyValue
+			for _, language := range languageNames {
+				collisions = append(collisions, findCaseCollisions(languageEntries[language])...)
+			}
+			reportCaseCollisions(output, collisions, quiet)
+		}
+
+		if summaryOnly && !quiet {
+			printSummaryTotals(output, totals)
+		}
+
+		if !quiet {
+			fmt.Fprintf(output, "Scanned %d files, %d with duplicate keys. Skipped %d excluded files.\n",
+				len(files), filesWithDuplicates, skipped)
+			if cache != nil {
+				fmt.Fprintf(output, "Reused cached results for %d files.\n", cache.hits)
+			}
+		}
+
+		overBudget := maxDuplicates >= 0 && !checkDuplicateBudget(output, totalDuplicates, maxDuplicates, quiet)
+		if (failOnDuplicates && filesWithDuplicates > 0) || (failOnConflicts && filesWithConflicts > 0) || overBudget ||
+			(failOnPlaceholderTypes && placeholderTypeMismatches > 0) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Analyze the file
+	parseStart := time.Now()
+	result, err := analyzeLocalizationFile(inputFile, parseOptions)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to open file: %w", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
-	defer file.Close()
+	parseTime := time.Since(parseStart)
+	reportStart := time.Now()
+	duplicateKeys := result.DuplicateKeys
 
-	// Map to track keys and all their occurrences
-	keyEntries := make(map[string][]KeyValue)
+	// Rewriting only knows the .strings line format
+	if result.Format == "po" && (cleanFile != "" || dedupeInPlace || repairFile != "" || syncBaseFile != "" || splitDir != "") {
+		fmt.Printf("Error: -clean, -dedupe-in-place, -repair, -sync-new and -split don't support .po files\n")
+		os.Exit(1)
+	}
 
-	// Map to track duplicate keys (keys with multiple entries)
-	duplicateKeys := make(map[string][]KeyValue)
+	// Drop intentionally duplicated keys from the report
+	duplicateKeys = dropIgnoredKeys(result.DuplicateKeys, ignoredKeys)
+	ignoredDuplicates := len(result.DuplicateKeys) - len(duplicateKeys)
 
-	// Map to store unique entries (first occurrence of each key)
-	uniqueEntries := make(map[string]KeyValue)
+	// Only keep duplicates that involve lines added since the git ref
+	olderDuplicates := 0
+	if sinceRef != "" {
+		added, err := addedLinesSince(inputFile, sinceRef)
+		if err != nil {
+			if !quiet {
+				fmt.Printf("Warning: %v; reporting all duplicates\n", err)
+			}
+		} else {
+			newDuplicates := filterNewDuplicates(duplicateKeys, added)
+			olderDuplicates = len(duplicateKeys) - len(newDuplicates)
+			duplicateKeys = newDuplicates
+		}
+	}
 
-	// Store all raw lines for recreating the file
-	var rawLines []string
+	// Sections of the text report go to textOutput, which drops them from
+	// -json and -summary-line output so that stays machine-readable. The
+	// checks behind them still run and still fail the run.
+	textOutput := output
+	if jsonOutput || summaryLine {
+		textOutput = io.Discard
+	}
 
-	// Regular expression to extract key-value pairs
-	// This pattern matches: "key" = "value";
-	kvPattern := regexp.MustCompile(`"([^"]+)"\s*=\s*"([^"]+)"\s*;`)
+	// Report #include chains that loop back on themselves
+	if len(result.IncludeCycles) > 0 && quiet {
+		for _, cycle := range result.IncludeCycles {
+			fmt.Fprintf(textOutput, "%s: include cycle: %s\n", inputFile, cycle)
+		}
+	} else if len(result.IncludeCycles) > 0 {
+		fmt.Fprintf(textOutput, "Include cycles found: %d\n", len(result.IncludeCycles))
+		fmt.Fprintf(textOutput, "====================\n")
+		for _, cycle := range result.IncludeCycles {
+			fmt.Fprintf(textOutput, "  %s\n", cycle)
+		}
+		fmt.Fprintf(textOutput, "\n")
+	}
 
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
+	// Report files edited with both \r\n and \n line endings
+	if result.LineEndings.Mixed() {
+		reportMixedLineEndings(textOutput, inputFile, result.LineEndings, quiet)
+	}
 
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-		rawLines = append(rawLines, line)
+	// Report fuzzy and untranslated gettext entries
+	if result.Format == "po" {
+		reportGettextStatus(textOutput, inputFile, result, quiet)
+	}
 
-		// Skip comment lines or empty lines for key analysis
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "//") {
-			continue
-		}
+	// Report entries with an empty key
+	if len(result.EmptyKeys) > 0 {
+		reportEmptyKeys(textOutput, inputFile, result.EmptyKeys, quiet)
+	}
 
-		matches := kvPattern.FindStringSubmatch(line)
-		if len(matches) == 3 {
-			key := matches[1]
-			value := matches[2]
+	// Explain why lines were skipped if requested
+	if explain {
+		reportSkippedLines(textOutput, inputFile, result, separator, quoteStyle, quiet)
+	}
 
-			// Add this entry to keyEntries
-			entry := KeyValue{
-				Key:     key,
-				Value:   value,
-				LineNum: lineNum,
-			}
+	// Report content after the closing semicolon in strict mode
+	if strict && len(result.TrailingLines) > 0 {
+		reportTrailingContent(textOutput, inputFile, result.TrailingLines, result.RawLines, quiet)
+	}
 
-			// Store first occurrence in uniqueEntries
-			if _, exists := uniqueEntries[key]; !exists {
-				uniqueEntries[key] = entry
-			}
+	// Exit with status 1 if the other checks found violations or the
+	// duplicates fail -fail-on-duplicates, -fail-on-conflicts,
+	// -max-duplicates or -strict
+	exitOnFailure := func(violations int) {
+		overBudget := maxDuplicates >= 0 && !checkDuplicateBudget(textOutput, countDuplicates(duplicateKeys), maxDuplicates, quiet)
+		if violations > 0 || (failOnDuplicates && len(duplicateKeys) > 0) || overBudget ||
+			(failOnConflicts && countConflicts(duplicateKeys) > 0) || (strict && len(result.EmptyKeys)+len(result.TrailingLines) > 0) {
+			// os.Exit skips deferred calls, so finish the profile first
+			pprof.StopCPUProfile()
+			os.Exit(1)
+		}
+	}
 
-			keyEntries[key] = append(keyEntries[key], entry)
+	// Print a single machine-readable line instead of the report
+	if summaryLine {
+		fmt.Fprintf(output, "entries=%d unique=%d duplicates=%d conflicts=%d\n",
+			len(result.Entries), len(result.UniqueEntries), countDuplicates(duplicateKeys), countConflicts(duplicateKeys))
+		exitOnFailure(0)
+		return
+	}
 
-			// If we now have more than one entry for this key, it's a duplicate
-			if len(keyEntries[key]) > 1 {
-				duplicateKeys[key] = keyEntries[key]
+	// Compare against another file instead of reporting duplicates
+	if compareFile != "" {
+		other, err := analyzeLocalizationFile(compareFile, parseOptions)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		diffOptions := analyzer.DiffOptions{IgnoreWhitespace: ignoreWhitespace, AllowReorderedPlaceholders: allowReorder}
+		diff := analyzer.DiffWithOptions(result, other, diffOptions)
+		if keysOnly {
+			if err := printKeysOnlyDiff(output, diff, jsonOutput); err != nil {
+				fmt.Printf("Error writing JSON report: %v\n", err)
+				os.Exit(1)
 			}
+			return
 		}
+		printDiff(output, inputFile, compareFile, diff, valueDiff)
+		if checkLineCounts {
+			printLineCountMismatches(output, result, other)
+		}
+		return
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, nil, nil, fmt.Errorf("error scanning file: %w", err)
+	// Compare the version of the file at a git ref with the working copy
+	if compareBranch != "" {
+		data, err := readFileAtRef(inputFile, compareBranch)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		base, err := analyzer.Parse(inputFile, bytes.NewReader(data), parseOptions)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		diffOptions := analyzer.DiffOptions{IgnoreWhitespace: ignoreWhitespace, AllowReorderedPlaceholders: allowReorder}
+		baseLabel := compareBranch + ":" + inputFile
+		diff := analyzer.DiffWithOptions(base, result, diffOptions)
+		if keysOnly {
+			if err := printKeysOnlyDiff(output, diff, jsonOutput); err != nil {
+				fmt.Printf("Error writing JSON report: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		printDiff(output, baseLabel, inputFile, diff, valueDiff)
+		if checkLineCounts {
+			printLineCountMismatches(output, base, result)
+		}
+		return
 	}
 
-	return duplicateKeys, uniqueEntries, rawLines, nil
+	// Compare against every matching translation file instead of reporting duplicates
+	if compareAllGlob != "" {
+		paths, err := filepath.Glob(compareAllGlob)
+		if err != nil {
+			fmt.Printf("Error: invalid -compare-all pattern: %v\n", err)
+			os.Exit(1)
+		}
+
+		others := make(map[string]*analyzer.Result)
+		for _, path := range paths {
+			if filepath.Clean(path) == filepath.Clean(inputFile) {
+				continue
+			}
+			other, err := analyzeLocalizationFile(path, parseOptions)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			others[path] = other
+		}
+		printPresenceMatrix(output, result, others)
+		return
+	}
+
+	// Append stubs for missing base keys instead of reporting duplicates
+	if syncBaseFile != "" {
+		if inputFile == "-" {
+			fmt.Printf("Error: -sync-new can't be used with stdin input\n")
+			os.Exit(1)
+		}
+		base, err := analyzeLocalizationFile(syncBaseFile, parseOptions)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		added, err := appendMissingKeys(inputFile, base, result, separator)
+		if err != nil {
+			fmt.Printf("Error updating file: %v\n", err)
+			os.Exit(1)
+		}
+		if !quiet {
+			fmt.Fprintf(output, "Added %d stubs for keys missing from %s\n", added, inputFile)
+		}
+		return
+	}
+
+	// Check key order against a base file instead of reporting duplicates
+	if orderBaseFile != "" {
+		base, err := analyzeLocalizationFile(orderBaseFile, parseOptions)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !checkKeyOrder(output, base, result, quiet) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check InfoPlist.strings keys instead of reporting duplicates
+	if infoPlist {
+		required := []string(infoPlistRequired)
+		if len(required) == 0 {
+			required = defaultInfoPlistRequired
+		}
+		if !checkInfoPlistKeys(output, result, required, quiet) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Report translation coverage instead of duplicates
+	if coverageFile != "" {
+		base, err := analyzeLocalizationFile(coverageFile, parseOptions)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		coverage := computeCoverage(base, result)
+		coverage.BaseFile = coverageFile
+		coverage.File = inputFile
+		if jsonOutput {
+			err = writeJSON(output, coverage)
+		} else {
+			printCoverage(output, coverage)
+		}
+		if err != nil {
+			fmt.Printf("Error writing coverage report: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// File metadata from comments such as "// Translated-By: name"
+	var metadata []metadataEntry
+	if metadataPrefix != "" {
+		metadata = extractMetadata(result.RawLines, metadataPrefix)
+	}
+
+	// Report duplicate keys
+	if jsonOutput {
+		report := buildJSONReport(inputFile, result, duplicateKeys, sortBy)
+		report.Metadata = metadata
+		if err := writeJSON(output, report); err != nil {
+			fmt.Printf("Error writing JSON report: %v\n", err)
+			os.Exit(1)
+		}
+	} else if quiet {
+		printQuietDuplicates(output, inputFile, duplicateKeys, sortBy)
+	} else if summaryOnly {
+		var totals summaryTotals
+		totals.add(result, duplicateKeys)
+		printSummaryTotals(output, totals)
+	} else if len(onlyKeys) > 0 {
+		// Only report on the requested keys
+		for _, key := range onlyKeys {
+			if entries, isDuplicate := duplicateKeys[key]; isDuplicate {
+				printDuplicateGroup(output, key, entries, adjacency, result.RawLines, contextLines)
+			} else if entry, exists := result.UniqueEntries[key]; exists {
+				fmt.Fprintf(output, "Key: \"%s\" is unique (line %d)\n\n", key, entry.LineNum)
+			} else {
+				fmt.Fprintf(output, "Key: \"%s\" not found\n\n", key)
+			}
+		}
+	} else {
+		printDuplicateReport(output, duplicateKeys, sortBy, topN, adjacency, result.RawLines, contextLines)
+	}
+
+	if metadataPrefix != "" && !quiet {
+		printMetadata(textOutput, metadata)
+	}
+
+	// Show the distribution of duplicate counts if requested
+	if dupHistogram {
+		printDuplicateHistogram(textOutput, duplicateKeys)
+	}
+
+	// Write a SARIF report for code scanning if requested
+	if sarifFile != "" {
+		if err := writeSARIF(sarifFile, inputFile, duplicateKeys, result.MalformedLines, result.RawLines); err != nil {
+			fmt.Printf("Error writing SARIF report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Write a Markdown report for PR comments if requested
+	if markdownFile != "" {
+		if err := writeMarkdownReport(markdownFile, inputFile, duplicateKeys, sortBy); err != nil {
+			fmt.Printf("Error writing Markdown report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Export unique entries if requested
+	if exportFile != "" {
+		if err := exportEntries(exportFile, result, sortOutput); err != nil {
+			fmt.Printf("Error exporting entries: %v\n", err)
+			os.Exit(1)
+		}
+		if !quiet {
+			fmt.Printf("Exported %d entries to %s\n", len(result.KeyOrder), exportFile)
+		}
+	}
+
+	// Split the file into one file per key namespace if requested
+	if splitDir != "" {
+		files, withoutNamespace, err := splitByNamespace(splitDir, result)
+		if err != nil {
+			fmt.Printf("Error splitting file: %v\n", err)
+			os.Exit(1)
+		}
+		if !quiet {
+			fmt.Printf("Created %d files in %s. %d keys had no namespace and were written to %s.\n",
+				files, splitDir, withoutNamespace, splitDefaultFile)
+		}
+	}
+
+	// Generate Go source from the unique entries if requested
+	if goGenFile != "" {
+		if err := writeGoSource(goGenFile, goPackage, inputFile, result); err != nil {
+			fmt.Printf("Error generating Go source: %v\n", err)
+			os.Exit(1)
+		}
+		if !quiet {
+			fmt.Printf("Wrote %d entries to %s\n", len(result.UniqueEntries), goGenFile)
+		}
+	}
+
+	// Report keys that are both commented out and active
+	if includeCommented {
+		reportCommentedKeys(textOutput, result, quiet)
+	}
+
+	// Check markup balance if requested
+	if checkMarkup {
+		tags := make(map[string]bool)
+		for _, tag := range strings.Split(markupTags, ",") {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				tags[tag] = true
+			}
+		}
+		reportValueIssues(textOutput, "Markup issues", result.Entries, func(value string) []string {
+			return findMarkupIssues(value, tags)
+		}, quiet)
+	}
+
+	// Check bracket balance if requested
+	if checkBrackets {
+		reportValueIssues(textOutput, "Bracket issues", result.Entries, findBracketIssues, quiet)
+	}
+
+	// Check for control characters if requested
+	if checkControl {
+		reportValueIssues(textOutput, "Control characters", result.Entries, func(value string) []string {
+			return findControlChars(value, allowedControl)
+		}, quiet)
+	}
+
+	// Check for leftover debug strings such as "!!!!!!" if requested
+	if maxRepeat > 0 {
+		reportValueIssues(textOutput, "Repeated character runs", result.Entries, func(value string) []string {
+			return findRepeatedRuns(value, maxRepeat)
+		}, quiet)
+	}
+
+	// Check for values broken over several lines if requested
+	if checkNewlines {
+		reportLiteralNewlines(textOutput, result.RawLines, separator, quiet)
+	}
+
+	// Check keys for characters that point to a mis-parsed line if requested
+	if checkKeyChars {
+		reportKeyCharIssues(textOutput, result.Entries, result.RawLines, separator, quiet)
+	}
+
+	// Check keys of included files against the file's own keys if requested
+	if checkCaseCollisions {
+		var entries []analyzer.KeyValue
+		seen := make(map[string]bool)
+		for _, entry := range result.Entries {
+			if entry.File == "" {
+				entry.File = inputFile
+			}
+			if id := entry.File + "\x00" + entry.Key; !seen[id] {
+				seen[id] = true
+				entries = append(entries, entry)
+			}
+		}
+		reportCaseCollisions(textOutput, findCaseCollisions(entries), quiet)
+	}
+
+	// Check capitalization consistency if requested
+	if checkCase {
+		reportCapitalization(textOutput, result, caseSeparator, quiet)
+	}
+
+	// Check values against a dictionary if requested
+	if spellcheckFile != "" {
+		dictionary, err := readWordList(spellcheckFile)
+		if err != nil {
+			fmt.Printf("Error reading dictionary: %v\n", err)
+			os.Exit(1)
+		}
+		if spellcheckIgnoreFile != "" {
+			ignored, err := readWordList(spellcheckIgnoreFile)
+			if err != nil {
+				fmt.Printf("Error reading spellcheck ignore list: %v\n", err)
+				os.Exit(1)
+			}
+			for word := range ignored {
+				dictionary[word] = true
+			}
+		}
+		reportUnknownWords(textOutput, result, dictionary, quiet)
+	}
+
+	// Check for comments left behind by deleted keys if requested
+	if checkOrphanedComments {
+		reportOrphanedComments(textOutput, inputFile, result, quiet)
+	}
+
+	// Check for keys that only differ by leading or trailing separators if requested
+	if checkTrimmedKeys {
+		reportTrimmedKeyCollisions(textOutput, result, quiet)
+	}
+
+	// Check for values that lost their literal text if requested
+	if checkPlaceholderOnly {
+		reportPlaceholderOnlyValues(textOutput, result.Entries, quiet)
+	}
+
+	// Check for escape sequences iOS renders literally or drops if requested
+	if checkEscapes {
+		reportInvalidEscapes(textOutput, result.Entries, quiet)
+	}
+
+	// Check for keys with a space typed instead of an underscore if requested
+	if checkKeyWhitespace {
+		reportWhitespaceKeyCollisions(textOutput, result, quiet)
+	}
+
+	// Check for keys that only differ by namespace if requested
+	if namespacePrefix != "" {
+		pattern, err := regexp.Compile(namespacePrefix)
+		if err != nil {
+			fmt.Printf("Error: invalid -namespace-prefix pattern: %v\n", err)
+			os.Exit(1)
+		}
+		reportNamespaceDuplicates(textOutput, result, pattern, quiet)
+	}
+
+	// Check %{key} references if requested
+	if checkRefs {
+		reportKeyReferences(textOutput, result, quiet)
+	}
+
+	// Print value length statistics if requested
+	if showStats {
+		printValueStats(textOutput, result.Entries, statsPerValue, maxGraphemes, quiet)
+	}
+
+	// Check value lengths if requested
+	var lengthViolations int
+	if maxLen > 0 {
+		lengthViolations = reportLongValues(textOutput, result.Entries, maxLen, quiet)
+	}
+
+	// Run the external lint command if requested
+	var lintViolations int
+	if lintCommand != "" {
+		lintViolations, err = runLintCommand(textOutput, lintCommand, lintJobs, result, quiet)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	reportTime := time.Since(reportStart)
+
+	// Write a repaired copy if requested
+	if repairFile != "" {
+		if filepath.Clean(repairFile) == filepath.Clean(inputFile) {
+			fmt.Printf("Error: Repair file cannot be the same as input file.\n")
+			os.Exit(1)
+		}
+		if err := writeRepairedFile(textOutput, repairFile, result, separator, quoteStyle, quiet); err != nil {
+			fmt.Printf("Error writing repaired file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Create a cleaned file if requested
+	cleanStart := time.Now()
+	if dryRun && (cleanFile != "" || dedupeInPlace) {
+		fmt.Printf("Dry run: no file was written.\n")
+		printCleanCounts(countFileEntries(result), countDuplicates(result.DuplicateKeys))
+	} else if cleanFile != "" || dedupeInPlace {
+		originalFile := inputFile
+		if dedupeInPlace {
+			// Overwrite the input, but keep a copy of the original first
+			backupFile := inputFile + ".bak"
+			originalFile = backupFile
+			if err := copyFile(inputFile, backupFile); err != nil {
+				fmt.Printf("Error creating backup: %v\n", err)
+				os.Exit(1)
+			}
+			cleanFile = inputFile
+			if !quiet {
+				fmt.Printf("Saved backup of original file to %s\n", backupFile)
+			}
+		} else if filepath.Clean(cleanFile) == filepath.Clean(inputFile) {
+			// Make sure we're not overwriting the input file
+			// Suggest a different name based on the input file
+			suggestedName := createUniqueFilename(inputFile)
+			fmt.Printf("Error: Clean file cannot be the same as input file.\n")
+			fmt.Printf("Please use a different filename, e.g., '%s', or -dedupe-in-place to clean it in place with a backup\n", suggestedName)
+			os.Exit(1)
+		}
+
+		cleanOpts := cleanOptions{
+			StripControl:   stripControl,
+			AllowedControl: allowedControl,
+			CRLF:           lineEndings == "crlf",
+
+			StripOrphanedComments: stripOrphanedComments,
+			StripComments:         stripComments,
+			KeepBlankLines:        keepBlankLines,
+		}
+		if len(renames) > 0 {
+			var warnings []string
+			cleanOpts.RenameKeys, warnings, err = planRenames(result.KeyOrder, renames)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, warning := range warnings {
+				fmt.Printf("Warning: %s\n", warning)
+			}
+		}
+		if len(valueReplacements)+len(regexReplacements) > 0 {
+			replacers, err := parseValueReplacements(valueReplacements, regexReplacements)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			var replacements int
+			cleanOpts.ReplaceValues, replacements = planValueReplacements(result.Entries, replacers)
+			reportValueReplacements(output, result.Entries, cleanOpts.ReplaceValues, replacements, quiet)
+		}
+		if normalizeKeys != "" {
+			var collisions map[string][]string
+			cleanOpts.RenameKeys, collisions = planKeyNormalization(result.KeyOrder, normalizeKeys)
+			reportNormalizationCollisions(output, result, collisions, quiet)
+		}
+		if cleanInteractive {
+			cleanOpts.KeepLine, err = chooseConflictValues(os.Stdin, os.Stdout, result.DuplicateKeys)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		removed, err := createCleanFile(cleanFile, result, cleanOpts)
+		if err != nil {
+			fmt.Printf("Error creating clean file: %v\n", err)
+			os.Exit(1)
+		}
+		if !quiet {
+			fmt.Printf("Created cleaned file at %s\n", cleanFile)
+			printCleanCounts(countFileEntries(result), removed)
+			if inputFile != "-" {
+				printSizeSaved(originalFile, cleanFile)
+			}
+			if len(cleanOpts.RenameKeys) > 0 {
+				fmt.Printf("Renamed %d keys.\n", len(cleanOpts.RenameKeys))
+			}
+		}
+	}
+
+	cleanTime := time.Since(cleanStart)
+
+	if profile {
+		fmt.Printf("Parsing: %v\n", parseTime)
+		fmt.Printf("Reporting: %v\n", reportTime)
+		fmt.Printf("Cleaning: %v\n", cleanTime)
+	}
+
+	// Print summary if outputting to file or in verbose mode
+	if (outputFile != "" || verbose) && !quiet {
+		if len(duplicateKeys) > 0 {
+			fmt.Printf("Analysis complete. Found %d duplicate keys with %d total duplicated entries.\n",
+				len(duplicateKeys), countDuplicates(duplicateKeys))
+			waste := duplicateBytes(duplicateKeys, result.RawLines)
+			fmt.Printf("Duplicate entries take up %d bytes (%.1f KB) beyond the first occurrence of each key.\n",
+				waste, float64(waste)/1024)
+
+			if outputFile != "" {
+				fmt.Printf("Results written to %s\n", outputFile)
+			}
+
+			if cleanFile == "" {
+				fmt.Println("Use -clean=filename.strings to create a cleaned version with duplicates removed.")
+			}
+		} else if verbose {
+			fmt.Println("No duplicate keys found.")
+		}
+
+		if ignoreFile != "" && ignoredDuplicates > 0 {
+			fmt.Printf("Ignored %d duplicate keys listed in %s.\n", ignoredDuplicates, ignoreFile)
+		}
+		if olderDuplicates > 0 {
+			fmt.Printf("Skipped %d duplicate keys that only involve lines from before %s.\n", olderDuplicates, sinceRef)
+		}
+	}
+
+	exitOnFailure(lengthViolations + lintViolations)
+}
+
+// stringListFlag collects the values of a flag that may be repeated
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// configFileName is read from the working directory at startup. It holds
+// default flag values, typically checked into the repository so that a
+// team runs the analyzer with the same settings.
+const configFileName = ".stringsanalyzer.json"
+
+// loadConfigFile reads the JSON config file, an object mapping flag names
+// to values, and returns the values of each flag as strings. Values may be
+// strings, numbers, booleans, or arrays for flags that can be repeated.
+// A missing file is not an error and yields no defaults.
+func loadConfigFile(filename string) (map[string][]string, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	config := make(map[string][]string)
+	for name, value := range raw {
+		values, err := configValues(value, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: option %q: %w", filename, name, err)
+		}
+		config[strings.TrimLeft(name, "-")] = values
+	}
+	return config, nil
+}
+
+// configValues converts a JSON config value to flag values
+func configValues(value interface{}, allowArray bool) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case []interface{}:
+		if allowArray {
+			var values []string
+			for _, item := range v {
+				itemValues, err := configValues(item, false)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, itemValues...)
+			}
+			return values, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
+
+// applyConfig sets every configured flag that wasn't given on the command
+// line, so command-line flags always win. A repeatable flag given on the
+// command line replaces the configured list instead of adding to it.
+func applyConfig(config map[string][]string) error {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var names []string
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q", name)
+		}
+		if given[name] {
+			continue
+		}
+		for _, value := range config[name] {
+			if err := flag.Set(name, value); err != nil {
+				return fmt.Errorf("invalid value %q for %q: %v", value, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// printDuplicateReport prints every duplicate group, or only the first topN
+// of them in the chosen sort order when topN is greater than zero
+func printDuplicateReport(output io.Writer, duplicateKeys map[string][]analyzer.KeyValue, sortBy string, topN, adjacency int, rawLines []string, context int) {
+	if len(duplicateKeys) == 0 {
+		fmt.Fprintf(output, "No duplicate keys found.\n")
+		return
+	}
+
+	fmt.Fprintf(output, "Duplicate keys found: %d\n", len(duplicateKeys))
+	fmt.Fprintf(output, "====================\n")
+
+	// Sort keys for consistent output
+	keys := sortDuplicateKeys(duplicateKeys, sortBy)
+
+	hidden := 0
+	if topN > 0 && len(keys) > topN {
+		hidden = len(keys) - topN
+		keys = keys[:topN]
+	}
+
+	for _, key := range keys {
+		printDuplicateGroup(output, key, duplicateKeys[key], adjacency, rawLines, context)
+	}
+
+	if hidden > 0 {
+		fmt.Fprintf(output, "... and %d more.\n\n", hidden)
+	}
+}
+
+// sortDuplicateKeys orders duplicate keys alphabetically, or by descending
+// number of occurrences when sortBy is "count" (ties broken alphabetically)
+func sortDuplicateKeys(duplicateKeys map[string][]analyzer.KeyValue, sortBy string) []string {
+	var keys []string
+	for key := range duplicateKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if sortBy == "count" {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return len(duplicateKeys[keys[i]]) > len(duplicateKeys[keys[j]])
+		})
+	}
+
+	return keys
+}
+
+// printDuplicateHistogram prints a table of how many keys occur a given
+// number of times, sorted by occurrence count
+func printDuplicateHistogram(output io.Writer, duplicateKeys map[string][]analyzer.KeyValue) {
+	histogram := make(map[int]int)
+	for _, entries := range duplicateKeys {
+		histogram[len(entries)]++
+	}
+
+	var counts []int
+	for count := range histogram {
+		counts = append(counts, count)
+	}
+	sort.Ints(counts)
+
+	fmt.Fprintf(output, "Duplicate count distribution:\n")
+	fmt.Fprintf(output, "====================\n")
+	fmt.Fprintf(output, "%11s  %s\n", "Occurrences", "Keys")
+	for _, count := range counts {
+		fmt.Fprintf(output, "%11d  %4d\n", count, histogram[count])
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// printDuplicateGroup prints one duplicate key with its occurrences. With a
+// context greater than zero, that many raw lines around each occurrence are
+// printed below it.
+func printDuplicateGroup(output io.Writer, key string, entries []analyzer.KeyValue, adjacency int, rawLines []string, context int) {
+	// Adjacent copies are likely an accidental paste, scattered ones may be
+	// intentional overrides
+	placement := "scattered"
+	if occurrencesAdjacent(entries, adjacency) {
+		placement = "adjacent"
+	}
+	fmt.Fprintf(output, "Key: \"%s\" appears %d times (%s):\n", key, len(entries), placement)
+
+	allSame := allValuesSame(entries)
+	firstValue := entries[0].Value
+	placeholderMismatch := false
+	if allSame && sameComments(entries) {
+		fmt.Fprintf(output, "  Exact duplicate (including comment), safe to remove: \"%s\"\n", firstValue)
+	} else if allSame {
+		fmt.Fprintf(output, "  All entries have the same value, different comment: \"%s\"\n", firstValue)
+	} else if quoteStyleOnly(entries) {
+		fmt.Fprintf(output, "  NOTE: Values differ only in quote style (quote-style-only difference, not a conflict)\n")
+	} else {
+		fmt.Fprintf(output, "  WARNING: Key has different values (localization conflict)!\n")
+
+		// Picking a value with the wrong number of format arguments crashes the app
+		placeholderMismatch = placeholderCountsDiffer(entries)
+		if placeholderMismatch {
+			fmt.Fprintf(output, "  CRITICAL: Occurrences have different numbers of placeholders!\n")
+		}
+	}
+
+	// A key defined in two MARK sections was likely added by two features
+	sections := hasSections(entries)
+	if spansSections(entries) {
+		fmt.Fprintf(output, "  NOTE: Defined in different sections of the file\n")
+	}
+
+	fmt.Fprintf(output, "  Found at lines:\n")
+	for _, entry := range entries {
+		label := lineLabel(entry)
+		if sections {
+			label += sectionLabel(entry)
+		}
+		if placeholderMismatch {
+			fmt.Fprintf(output, "    %s: \"%s\" (%d placeholders)\n", label, entry.Value, countPlaceholders(entry.Value))
+		} else if !allSame {
+			fmt.Fprintf(output, "    %s: \"%s\"\n", label, entry.Value)
+		} else {
+			fmt.Fprintf(output, "    %s\n", label)
+		}
+		if context > 0 && entry.File == "" {
+			printLineContext(output, rawLines, entry.LineNum, context)
+		}
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// hasSections reports whether any occurrence is under a MARK section
+func hasSections(entries []analyzer.KeyValue) bool {
+	for _, entry := range entries {
+		if entry.Section != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// spansSections reports whether the occurrences of a key are in different
+// MARK sections of the same file. Occurrences before the first MARK count
+// as a section of their own.
+func spansSections(entries []analyzer.KeyValue) bool {
+	for _, entry := range entries[1:] {
+		if entry.File == entries[0].File && entry.Section != entries[0].Section {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionLabel describes the MARK section of an occurrence for the report
+func sectionLabel(entry analyzer.KeyValue) string {
+	if entry.Section == "" {
+		return " (no section)"
+	}
+	return fmt.Sprintf(" (section \"%s\")", entry.Section)
+}
+
+// printLineContext prints the raw lines around lineNum, like grep -C, with
+// the line itself marked by ">"
+func printLineContext(output io.Writer, rawLines []string, lineNum, context int) {
+	first := lineNum - context
+	if first < 1 {
+		first = 1
+	}
+	last := lineNum + context
+	if last > len(rawLines) {
+		last = len(rawLines)
+	}
+
+	width := len(strconv.Itoa(last))
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == lineNum {
+			marker = ">"
+		}
+		fmt.Fprintln(output, strings.TrimRight(fmt.Sprintf("      %s %*d | %s", marker, width, n, rawLines[n-1]), " "))
+	}
+}
+
+// occurrencesAdjacent reports whether every occurrence is in the same file
+// and the first and last occurrence are at most window lines apart
+func occurrencesAdjacent(entries []analyzer.KeyValue, window int) bool {
+	first, last := entries[0].LineNum, entries[0].LineNum
+	for _, entry := range entries[1:] {
+		if entry.File != entries[0].File {
+			return false
+		}
+		if entry.LineNum < first {
+			first = entry.LineNum
+		}
+		if entry.LineNum > last {
+			last = entry.LineNum
+		}
+	}
+	return last-first <= window
+}
+
+// printQuietDuplicates prints one line per repeated occurrence of a
+// duplicate key, in file:line form, and nothing when there are no duplicates
+func printQuietDuplicates(output io.Writer, inputFile string, duplicateKeys map[string][]analyzer.KeyValue, sortBy string) {
+	keys := sortDuplicateKeys(duplicateKeys, sortBy)
+
+	for _, key := range keys {
+		entries := duplicateKeys[key]
+		first := entries[0]
+		for _, entry := range entries[1:] {
+			file := inputFile
+			if entry.File != "" {
+				file = entry.File
+			}
+
+			if entry.Value != first.Value && !quoteStyleOnly(entries) {
+				fmt.Fprintf(output, "%s:%d: conflicting duplicate key \"%s\" (%s has \"%s\", this line has \"%s\")\n",
+					file, entry.LineNum, key, strings.ToLower(lineLabel(first)), first.Value, entry.Value)
+			} else {
+				fmt.Fprintf(output, "%s:%d: duplicate key \"%s\" (first defined at %s)\n",
+					file, entry.LineNum, key, strings.ToLower(lineLabel(first)))
+			}
+		}
+	}
+}
+
+// countPlaceholders returns the number of format specifiers in value
+func countPlaceholders(value string) int {
+	count := 0
+	for _, token := range analyzer.ExtractTokens(value) {
+		if token.Kind == analyzer.TokenPrintf {
+			count++
+		}
+	}
+	return count
+}
+
+// placeholderCountsDiffer reports whether the entries don't all have the
+// same number of format specifiers
+func placeholderCountsDiffer(entries []analyzer.KeyValue) bool {
+	first := countPlaceholders(entries[0].Value)
+	for _, entry := range entries[1:] {
+		if countPlaceholders(entry.Value) != first {
+			return true
+		}
+	}
+	return false
+}
+
+// isConflict reports whether a duplicate group has genuinely different values,
+// ignoring differences in quote style
+func isConflict(entries []analyzer.KeyValue) bool {
+	return !allValuesSame(entries) && !quoteStyleOnly(entries)
+}
+
+// lineLabel describes where an entry was found, naming the source file for
+// entries pulled in by #include
+func lineLabel(entry analyzer.KeyValue) string {
+	if entry.File != "" {
+		return fmt.Sprintf("Line %d in %s", entry.LineNum, entry.File)
+	}
+	return fmt.Sprintf("Line %d", entry.LineNum)
+}
+
+// allValuesSame reports whether every entry has the same value as the first one
+func allValuesSame(entries []analyzer.KeyValue) bool {
+	for _, entry := range entries[1:] {
+		if entry.Value != entries[0].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// sameComments reports whether every entry has the same comment above it as
+// the first one, counting no comment as an empty comment
+func sameComments(entries []analyzer.KeyValue) bool {
+	for _, entry := range entries[1:] {
+		if entry.Comment != entries[0].Comment {
+			return false
+		}
+	}
+	return true
+}
+
+// isExactDuplicate reports whether every occurrence repeats both the value
+// and the comment of the first one, i.e. the whole entry was copy-pasted
+func isExactDuplicate(entries []analyzer.KeyValue) bool {
+	return allValuesSame(entries) && sameComments(entries)
+}
+
+// quoteReplacer maps typographic quotes to their straight equivalents
+var quoteReplacer = strings.NewReplacer(
+	"\u2018", "'", "\u2019", "'", "\u201A", "'", "\u201B", "'",
+	"\u201C", "\"", "\u201D", "\"", "\u201E", "\"", "\u201F", "\"",
+	"\u00AB", "\"", "\u00BB", "\"",
+)
+
+// quoteStyleOnly reports whether entries with differing values become identical
+// once typographic quotes are replaced by straight ones
+func quoteStyleOnly(entries []analyzer.KeyValue) bool {
+	first := quoteReplacer.Replace(entries[0].Value)
+	for _, entry := range entries[1:] {
+		if quoteReplacer.Replace(entry.Value) != first {
+			return false
+		}
+	}
+	return true
+}
+
+func createUniqueFilename(filename string) string {
+	dir := filepath.Dir(filename)
+	base := filepath.Base(filename)
+	ext := filepath.Ext(filename)
+	nameWithoutExt := strings.TrimSuffix(base, ext)
+
+	return filepath.Join(dir, nameWithoutExt+"-cleaned"+ext)
+}
+
+// copyFile copies src to dst, keeping the source file's permissions
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to read file info: %w", err)
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if err := os.WriteFile(dst, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// countFileEntries counts the key-value entries of the input file itself,
+// leaving out entries merged in from #include files
+func countFileEntries(result *analyzer.Result) int {
+	count := 0
+	for _, entry := range result.Entries {
+		if entry.File == "" {
+			count++
+		}
+	}
+	return count
+}
+
+// printCleanCounts prints the entry count before and after cleaning
+func printCleanCounts(before, removed int) {
+	fmt.Printf("Before: %d entries, After: %d entries (removed %d)\n", before, before-removed, removed)
+}
+
+// printSizeSaved prints the size of the original and the cleaned file and
+// how much cleaning saved. Nothing is printed if either can't be read.
+func printSizeSaved(originalFile, cleanFile string) {
+	originalInfo, err := os.Stat(originalFile)
+	if err != nil {
+		return
+	}
+	cleanInfo, err := os.Stat(cleanFile)
+	if err != nil {
+		return
+	}
+
+	saved := originalInfo.Size() - cleanInfo.Size()
+	percent := 0.0
+	if originalInfo.Size() > 0 {
+		percent = float64(saved) * 100 / float64(originalInfo.Size())
+	}
+	fmt.Printf("Size: %d bytes before, %d bytes after (saved %d bytes, %.1f%%)\n", originalInfo.Size(), cleanInfo.Size(), saved, percent)
+}
+
+// summaryTotals are the counts -summary-only prints, summed over every
+// scanned file
+type summaryTotals struct {
+	Entries          int
+	UniqueKeys       int
+	DuplicateKeys    int
+	DuplicateEntries int
+	Conflicts        int
+}
+
+// add counts the entries of result and the given duplicate groups, which
+// may be a filtered subset of result.DuplicateKeys
+func (t *summaryTotals) add(result *analyzer.Result, duplicateKeys map[string][]analyzer.KeyValue) {
+	t.Entries += len(result.Entries)
+	t.UniqueKeys += len(result.UniqueEntries)
+	t.DuplicateKeys += len(duplicateKeys)
+	t.DuplicateEntries += countDuplicates(duplicateKeys)
+	t.Conflicts += countConflicts(duplicateKeys)
+}
+
+// printSummaryTotals prints the -summary-only report
+func printSummaryTotals(output io.Writer, totals summaryTotals) {
+	fmt.Fprintf(output, "Summary\n")
+	fmt.Fprintf(output, "====================\n")
+	fmt.Fprintf(output, "Total entries: %d\n", totals.Entries)
+	fmt.Fprintf(output, "Unique keys: %d\n", totals.UniqueKeys)
+	fmt.Fprintf(output, "Duplicate keys: %d (%d duplicated entries)\n", totals.DuplicateKeys, totals.DuplicateEntries)
+	fmt.Fprintf(output, "Conflicts: %d\n\n", totals.Conflicts)
+}
+
+// countConflicts counts the duplicate keys whose occurrences have different
+// values (quote-style-only differences don't count)
+func countConflicts(duplicateKeys map[string][]analyzer.KeyValue) int {
+	count := 0
+	for _, entries := range duplicateKeys {
+		if isConflict(entries) {
+			count++
+		}
+	}
+	return count
+}
+
+func countDuplicates(duplicateKeys map[string][]analyzer.KeyValue) int {
+	count := 0
+	for _, entries := range duplicateKeys {
+		count += len(entries) - 1 // Count all occurrences beyond the first one
+	}
+	return count
+}
+
+// checkDuplicateBudget reports how much of the -max-duplicates budget the
+// duplicate entries use and returns false when they exceed it. Going over
+// the budget is reported even in quiet mode.
+func checkDuplicateBudget(output io.Writer, duplicates, budget int, quiet bool) bool {
+	if duplicates > budget {
+		fmt.Fprintf(output, "Duplicate budget exceeded: %d/%d budget used.\n", duplicates, budget)
+		return false
+	}
+	if !quiet {
+		fmt.Fprintf(output, "%d/%d budget used.\n", duplicates, budget)
+	}
+	return true
+}
+
+// duplicateBytes sums the size of the raw lines of every duplicate
+// occurrence beyond the first, including their line breaks: the bytes that
+// removing the duplicates would save. Sizes are in UTF-8, and occurrences
+// from #include files are not counted.
+func duplicateBytes(duplicateKeys map[string][]analyzer.KeyValue, rawLines []string) int {
+	total := 0
+	for _, entries := range duplicateKeys {
+		for _, entry := range entries[1:] {
+			if entry.File == "" && entry.LineNum <= len(rawLines) {
+				total += len(rawLines[entry.LineNum-1]) + 1
+			}
+		}
+	}
+	return total
+}
+
+// cleanOptions controls which occurrences createCleanFile keeps
+type cleanOptions struct {
+	// KeepLine maps a duplicate key to the line number of the occurrence to
+	// keep, or 0 to keep every occurrence. Other keys keep their first occurrence.
+	KeepLine map[string]int
+
+	// StripControl removes control characters from values, except AllowedControl
+	StripControl   bool
+	AllowedControl map[rune]bool
+
+	// RenameKeys maps original keys to the key they are written out as
+	RenameKeys map[string]string
+
+	// ReplaceValues maps the line number of an entry to the value it is
+	// written out with
+	ReplaceValues map[int]string
+
+	// StripOrphanedComments leaves out comment blocks that no key follows
+	StripOrphanedComments bool
+
+	// StripComments leaves out every // and /* */ comment line, and blank
+	// lines too unless KeepBlankLines is set
+	StripComments  bool
+	KeepBlankLines bool
+
+	// CRLF writes every line with \r\n instead of \n
+	CRLF bool
+}
+
+// createCleanFile writes the input without duplicate entries and returns the
+// number of entries removed
+func createCleanFile(filename string, result *analyzer.Result, opts cleanOptions) (int, error) {
+	// Create the directory if it doesn't exist
+	dir := filepath.Dir(filename)
+	if dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	// Write the cleaned file back in the encoding the input was read with
+	encoder, err := newLineEncoder(result)
+	if err != nil {
+		return 0, err
+	}
+
+	cleanFile, err := os.Create(filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create clean file: %w", err)
+	}
+	defer cleanFile.Close()
+	out := bufio.NewWriter(cleanFile)
+
+	// Every line is written with the same ending, whatever the input used.
+	// The first error stops the writing and is returned at the end.
+	lineEnding := "\n"
+	if opts.CRLF {
+		lineEnding = "\r\n"
+	}
+	var writeErr error
+	writeLine := func(lineNum int, line string) {
+		if writeErr != nil {
+			return
+		}
+		encoded, err := encoder.encode(lineNum, line+lineEnding)
+		if err == nil {
+			_, err = out.WriteString(encoded)
+		}
+		writeErr = err
+	}
+
+	// First, write all non-key-value lines (comments, empty lines)
+	// and the first occurrence of each key
+	writtenKeys := make(map[string]bool)
+	removed := 0
+
+	// Key-value lines found by the parser, by line number
+	entryLines := make(map[int]analyzer.KeyValue)
+	for _, entry := range result.Entries {
+		if entry.File == "" {
+			entryLines[entry.LineNum] = entry
+		}
+	}
+
+	// Exact duplicates repeat their comment too, so the comment of every
+	// removed copy goes with it instead of being left behind
+	droppedLines := make(map[int]bool)
+	for key, entries := range result.DuplicateKeys {
+		if _, chosen := opts.KeepLine[key]; chosen || !isExactDuplicate(entries) {
+			continue
+		}
+		first := true
+		for _, entry := range entries {
+			if entry.File != "" {
+				continue
+			}
+			if !first && entry.CommentLine != 0 {
+				for line := entry.CommentLine; line < entry.LineNum; line++ {
+					droppedLines[line] = true
+				}
+			}
+			first = false
+		}
+	}
+	if opts.StripOrphanedComments {
+		for _, block := range result.OrphanedComments {
+			for line := block.Start; line <= block.End; line++ {
+				droppedLines[line] = true
+			}
+		}
+	}
+
+	inBlockComment := false
+	for i, line := range result.RawLines {
+		if droppedLines[i+1] {
+			continue
+		}
+		trimmedLine := strings.TrimSpace(line)
+
+		// Write comments and empty lines as-is, unless they are stripped
+		if trimmedLine == "" {
+			if !opts.StripComments || opts.KeepBlankLines {
+				writeLine(i+1, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmedLine, "//") {
+			if !opts.StripComments {
+				writeLine(i+1, line)
+			}
+			continue
+		}
+
+		// Track /* ... */ blocks the same way the parser does
+		commentLine := inBlockComment || strings.HasPrefix(trimmedLine, "/*")
+		if inBlockComment {
+			inBlockComment = !strings.Contains(trimmedLine, "*/")
+		} else if commentLine {
+			inBlockComment = !strings.Contains(trimmedLine[2:], "*/")
+		}
+
+		// Look up the key if this is a key-value line
+		if entry, isEntry := entryLines[i+1]; isEntry {
+			key := entry.Key
+
+			value := entry.Value
+			if newValue, replaced := opts.ReplaceValues[entry.LineNum]; replaced {
+				value = newValue
+			}
+			if opts.StripControl {
+				value = stripControlChars(value, opts.AllowedControl)
+			}
+			line = replaceValue(line, entry, value)
+			if newKey, renamed := opts.RenameKeys[key]; renamed {
+				line = replaceKey(line, key, newKey)
+			}
+
+			// Write the chosen occurrence if there is one, otherwise the first
+			if keepLine, chosen := opts.KeepLine[key]; chosen {
+				if keepLine == 0 || keepLine == entry.LineNum {
+					writeLine(i+1, line)
+				} else {
+					removed++
+				}
+			} else if !writtenKeys[key] {
+				writeLine(i+1, line)
+				writtenKeys[key] = true
+			} else {
+				// Otherwise, skip this duplicate
+				removed++
+			}
+		} else if !commentLine || !opts.StripComments {
+			// Write non-matching lines (not key-value format) as-is
+			writeLine(i+1, line)
+		}
+	}
+
+	if writeErr == nil {
+		writeErr = out.Flush()
+	}
+	if writeErr == nil {
+		writeErr = cleanFile.Close()
+	}
+	if writeErr != nil {
+		return 0, fmt.Errorf("failed to write clean file: %w", writeErr)
+	}
+	return removed, nil
+}
+
+// lineEncoder turns output lines back into the encoding the input was read
+// with. With an explicit -encoding every line is encoded. When the encoding
+// was detected, only the lines that fell back to Windows-1252 are, and the
+// rest stay UTF-8, so one Latin-1 line can't mangle text such as Japanese
+// on the other lines.
+type lineEncoder struct {
+	name     string
+	encoder  *encoding.Encoder // nil for UTF-8
+	fallback map[int]bool      // Lines to encode; nil when every line is
+}
+
+func newLineEncoder(result *analyzer.Result) (*lineEncoder, error) {
+	e := &lineEncoder{name: result.Encoding}
+	if result.Encoding == "" || result.Encoding == "utf-8" {
+		return e, nil
+	}
+	enc, err := htmlindex.Get(result.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q", result.Encoding)
+	}
+	e.encoder = enc.NewEncoder()
+	if len(result.FallbackLines) > 0 {
+		e.fallback = make(map[int]bool)
+		for _, lineNum := range result.FallbackLines {
+			e.fallback[lineNum] = true
+		}
+	}
+	return e, nil
+}
+
+// encode returns text from line lineNum of the input, or 0 for new text, in
+// the encoding it is written in. Text the encoding can't represent is an
+// error rather than being cut off.
+func (e *lineEncoder) encode(lineNum int, text string) (string, error) {
+	if e.encoder == nil || (e.fallback != nil && !e.fallback[lineNum]) {
+		return text, nil
+	}
+	encoded, err := e.encoder.String(text)
+	if err != nil {
+		return "", fmt.Errorf("%q can't be written as %s: %w", strings.TrimRight(text, "\r\n"), e.name, err)
+	}
+	return encoded, nil
+}
+
+// appendMissingKeys appends an entry for every base key the target file
+// doesn't define, using the base value as a placeholder and marking it with
+// a TODO comment. Existing lines are left untouched. It returns the number
+// of stubs added.
+func appendMissingKeys(filename string, base, target *analyzer.Result, separator string) (int, error) {
+	var stubs strings.Builder
+	added := 0
+	for _, key := range base.KeyOrder {
+		if _, exists := target.UniqueEntries[key]; exists {
+			continue
+		}
+		fmt.Fprintf(&stubs, "// TODO: translate\n\"%s\" %s \"%s\";\n", key, separator, base.UniqueEntries[key].Value)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	// Don't glue the first stub onto a last line without a line break
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	text := stubs.String()
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		text = "\n" + text
+	}
+
+	// Append in the encoding the file was read with
+	encoder, err := newLineEncoder(target)
+	if err != nil {
+		return 0, err
+	}
+	text, err = encoder.encode(0, text)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if _, err := io.WriteString(file, text); err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// repairLine tries to fix a malformed "key" = "value" line: a missing
+// trailing semicolon, odd whitespace (such as non-breaking spaces) around
+// the separator and unescaped quotes inside the value. Only those parts of
+// the line change; the rest, spacing included, is kept as written. It
+// returns the repaired line and the changes made, or no changes if the line
+// doesn't look like a key-value pair at all.
+func repairLine(line, separator, quote string) (string, []string) {
+	quotes := "\""
+	switch quote {
+	case "single":
+		quotes = "'"
+	case "any":
+		quotes = "\"'"
+	}
+
+	indent := line[:len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace))]
+	rest := line[len(indent):]
+	if rest == "" || !strings.ContainsRune(quotes, rune(rest[0])) {
+		return line, nil
+	}
+	keyQuote := rest[:1]
+
+	keyEnd := strings.Index(rest[1:], keyQuote)
+	if keyEnd <= 0 {
+		return line, nil
+	}
+	key := rest[:keyEnd+2]
+	rest = rest[keyEnd+2:]
+
+	// Whitespace around the separator
+	afterKey := strings.TrimLeftFunc(rest, unicode.IsSpace)
+	if !strings.HasPrefix(afterKey, separator) {
+		return line, nil
+	}
+	beforeSep := rest[:len(rest)-len(afterKey)]
+	afterSep := afterKey[len(separator):]
+	valueStart := strings.TrimLeftFunc(afterSep, unicode.IsSpace)
+	if valueStart == "" || !strings.ContainsRune(quotes, rune(valueStart[0])) {
+		return line, nil
+	}
+	afterSep = afterSep[:len(afterSep)-len(valueStart)]
+	valueQuote := valueStart[:1]
+
+	// The value runs to the last quote; only a semicolon may follow it
+	valueEnd := strings.LastIndex(valueStart, valueQuote)
+	if valueEnd <= 0 {
+		return line, nil
+	}
+	value := valueStart[1:valueEnd]
+	trailing := valueStart[valueEnd+1:]
+	if t := strings.TrimSpace(trailing); t != "" && t != ";" {
+		return line, nil
+	}
+
+	var changes []string
+	if strings.TrimSpace(trailing) == "" {
+		trailing = ";" + trailing
+		changes = append(changes, "added missing semicolon")
+	}
+	oddSpace := func(r rune) rune {
+		if unicode.IsSpace(r) && r != ' ' && r != '\t' {
+			return ' '
+		}
+		return r
+	}
+	if spacing := beforeSep + afterSep; strings.Map(oddSpace, spacing) != spacing {
+		beforeSep = strings.Map(oddSpace, beforeSep)
+		afterSep = strings.Map(oddSpace, afterSep)
+		changes = append(changes, fmt.Sprintf("normalized whitespace around %q", separator))
+	}
+
+	var escaped strings.Builder
+	innerQuotes := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			escaped.WriteString(value[i : i+2])
+			i++
+			continue
+		}
+		if value[i] == valueQuote[0] {
+			escaped.WriteString("\\" + valueQuote)
+			innerQuotes++
+			continue
+		}
+		escaped.WriteByte(value[i])
+	}
+	if innerQuotes > 0 {
+		changes = append(changes, fmt.Sprintf("escaped %d inner quotes", innerQuotes))
+	}
+
+	if len(changes) == 0 {
+		return line, nil
+	}
+	return indent + key + beforeSep + separator + afterSep + valueQuote + escaped.String() + valueQuote + trailing, changes
+}
+
+// writeRepairedFile writes the input with every malformed line that
+// repairLine can fix replaced, and reports each change. Well-formed lines
+// are copied unchanged, and lines end in \r\n if most of the input's did.
+func writeRepairedFile(output io.Writer, filename string, result *analyzer.Result, separator, quote string, quiet bool) error {
+	if separator == "" {
+		separator = "="
+	}
+
+	malformed := make(map[int]bool)
+	for _, lineNum := range result.MalformedLines {
+		malformed[lineNum] = true
+	}
+
+	lineEnding := "\n"
+	if result.LineEndings.CRLF > result.LineEndings.LF {
+		lineEnding = "\r\n"
+	}
+
+	// Write the repaired file in the encoding the input was read with
+	encoder, err := newLineEncoder(result)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	repaired := 0
+	for i, line := range result.RawLines {
+		if malformed[i+1] {
+			fixed, changes := repairLine(line, separator, quote)
+			if len(changes) > 0 {
+				if !quiet {
+					fmt.Fprintf(output, "Repaired line %d: %s\n", i+1, strings.Join(changes, ", "))
+				}
+				line = fixed
+				repaired++
+			}
+		}
+		encoded, err := encoder.encode(i+1, line+lineEnding)
+		if err != nil {
+			return err
+		}
+		b.WriteString(encoded)
+	}
+
+	if err := os.WriteFile(filename, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Fprintf(output, "Repaired %d of %d malformed lines and wrote %s\n\n", repaired, len(result.MalformedLines), filename)
+	}
+	return nil
+}
+
+// chooseConflictValues asks, for every duplicate key with differing values,
+// which occurrence to keep. Answering "skip" keeps every occurrence of the key.
+func chooseConflictValues(input io.Reader, prompt io.Writer, duplicateKeys map[string][]analyzer.KeyValue) (map[string]int, error) {
+	reader := bufio.NewReader(input)
+	keepLine := make(map[string]int)
+
+	var keys []string
+	for key := range duplicateKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entries := duplicateKeys[key]
+		if allValuesSame(entries) {
+			continue
+		}
+
+		fmt.Fprintf(prompt, "Key \"%s\" has different values:\n", key)
+		var choices []string
+		for _, entry := range entries {
+			fmt.Fprintf(prompt, "  Line %d: \"%s\"\n", entry.LineNum, entry.Value)
+			choices = append(choices, strconv.Itoa(entry.LineNum))
+		}
+
+		for {
+			fmt.Fprintf(prompt, "Keep which line? [%s/skip]: ", strings.Join(choices, "/"))
+			answer, err := reader.ReadString('\n')
+			if err != nil && answer == "" {
+				return nil, fmt.Errorf("no choice given for key \"%s\": %w", key, err)
+			}
+			answer = strings.TrimSpace(answer)
+
+			if answer == "skip" {
+				keepLine[key] = 0
+				break
+			}
+
+			lineNum, _ := strconv.Atoi(answer)
+			if lineNum > 0 && containsLine(entries, lineNum) {
+				keepLine[key] = lineNum
+				break
+			}
+			fmt.Fprintf(prompt, "Please enter one of the listed line numbers or \"skip\".\n")
+		}
+		fmt.Fprintln(prompt)
+	}
+
+	return keepLine, nil
+}
+
+func containsLine(entries []analyzer.KeyValue, lineNum int) bool {
+	for _, entry := range entries {
+		if entry.LineNum == lineNum {
+			return true
+		}
+	}
+	return false
+}
+
+// printKeysOnlyDiff prints the -keys-only form of a diff: the sorted added
+// and removed keys, as text or as a JSON object with "added" and "removed"
+// arrays
+func printKeysOnlyDiff(output io.Writer, diff analyzer.DiffResult, asJSON bool) error {
+	added := make([]string, 0, len(diff.Added))
+	for _, entry := range diff.Added {
+		added = append(added, entry.Key)
+	}
+	removed := make([]string, 0, len(diff.Removed))
+	for _, entry := range diff.Removed {
+		removed = append(removed, entry.Key)
+	}
+
+	if asJSON {
+		return writeJSON(output, struct {
+			Added   []string `json:"added"`
+			Removed []string `json:"removed"`
+		}{added, removed})
+	}
+
+	fmt.Fprintf(output, "Added keys: %d\n", len(added))
+	for _, key := range added {
+		fmt.Fprintf(output, "  + %s\n", key)
+	}
+	fmt.Fprintf(output, "Removed keys: %d\n", len(removed))
+	for _, key := range removed {
+		fmt.Fprintf(output, "  - %s\n", key)
+	}
+	return nil
+}
+
+// printPresenceMatrix prints which base keys are missing from which of the
+// other files as a key x language table, with keys and columns sorted. Only
+// keys missing somewhere get a row. Columns are named after the file's
+// language (its xx.lproj directory), or its path when there is none.
+func printPresenceMatrix(output io.Writer, base *analyzer.Result, others map[string]*analyzer.Result) {
+	if len(others) == 0 {
+		fmt.Fprintf(output, "No translation files to compare against.\n")
+		return
+	}
+
+	columns := make(map[string]string)
+	var names []string
+	for path := range others {
+		name := languageForPath(path)
+		if name == "unknown" || columns[name] != "" {
+			name = path
+		}
+		columns[name] = path
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keys := base.SortedKeys()
+
+	var missingKeys []string
+	missingCounts := make(map[string]int)
+	for _, key := range keys {
+		missing := false
+		for _, name := range names {
+			if _, exists := others[columns[name]].UniqueEntries[key]; !exists {
+				missingCounts[name]++
+				missing = true
+			}
+		}
+		if missing {
+			missingKeys = append(missingKeys, key)
+		}
+	}
+
+	if len(missingKeys) == 0 {
+		fmt.Fprintf(output, "All %d keys are present in all %d translation files.\n", len(keys), len(names))
+		return
+	}
+
+	fmt.Fprintf(output, "Keys missing from at least one translation: %d\n", len(missingKeys))
+	fmt.Fprintf(output, "====================\n")
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "Key\t%s\n", strings.Join(names, "\t"))
+	for _, key := range missingKeys {
+		cells := make([]string, len(names))
+		for i, name := range names {
+			cells[i] = "yes"
+			if _, exists := others[columns[name]].UniqueEntries[key]; !exists {
+				cells[i] = "MISSING"
+			}
+		}
+		fmt.Fprintf(writer, "%s\t%s\n", key, strings.Join(cells, "\t"))
+	}
+	cells := make([]string, len(names))
+	for i, name := range names {
+		cells[i] = strconv.Itoa(missingCounts[name])
+	}
+	fmt.Fprintf(writer, "Missing\t%s\n", strings.Join(cells, "\t"))
+	writer.Flush()
+	fmt.Fprintf(output, "\n")
+}
+
+// defaultInfoPlistRequired are the keys -infoplist requires unless
+// -infoplist-require is given
+var defaultInfoPlistRequired = []string{"CFBundleDisplayName"}
+
+// knownInfoPlistKeys are the Info.plist keys commonly localized in
+// InfoPlist.strings. Any NS...UsageDescription key is accepted as well, see
+// infoPlistUsagePattern.
+var knownInfoPlistKeys = map[string]bool{
+	"CFBundleDisplayName":                          true,
+	"CFBundleName":                                 true,
+	"CFBundleSpokenName":                           true,
+	"CFBundleGetInfoString":                        true,
+	"CFBundleShortVersionString":                   true,
+	"NSHumanReadableCopyright":                     true,
+	"NSAppleEventsUsageDescription":                true,
+	"NSBluetoothAlwaysUsageDescription":            true,
+	"NSCalendarsUsageDescription":                  true,
+	"NSCameraUsageDescription":                     true,
+	"NSContactsUsageDescription":                   true,
+	"NSFaceIDUsageDescription":                     true,
+	"NSLocationAlwaysAndWhenInUseUsageDescription": true,
+	"NSLocationWhenInUseUsageDescription":          true,
+	"NSMicrophoneUsageDescription":                 true,
+	"NSMotionUsageDescription":                     true,
+	"NSPhotoLibraryAddUsageDescription":            true,
+	"NSPhotoLibraryUsageDescription":               true,
+	"NSRemindersUsageDescription":                  true,
+	"NSSpeechRecognitionUsageDescription":          true,
+	"NSUserTrackingUsageDescription":               true,
+	"NSLocalNetworkUsageDescription":               true,
+	"NSHealthShareUsageDescription":                true,
+	"NSHealthUpdateUsageDescription":               true,
+	"NSSiriUsageDescription":                       true,
+	"NFCReaderUsageDescription":                    true,
+}
+
+// infoPlistUsagePattern matches privacy usage description keys, which Apple
+// adds with almost every OS release
+var infoPlistUsagePattern = regexp.MustCompile(`^NS[A-Za-z]+UsageDescription$`)
+
+// checkInfoPlistKeys reports required keys the InfoPlist.strings file
+// doesn't define and keys that aren't known Info.plist keys, which are
+// usually typos that iOS silently ignores. It returns whether every
+// required key is present; unknown keys are only warnings.
+func checkInfoPlistKeys(output io.Writer, result *analyzer.Result, required []string, quiet bool) bool {
+	var missing []string
+	for _, key := range required {
+		if _, exists := result.UniqueEntries[key]; !exists {
+			missing = append(missing, key)
+		}
+	}
+
+	var unknown []string
+	for _, key := range result.KeyOrder {
+		if !knownInfoPlistKeys[key] && !infoPlistUsagePattern.MatchString(key) && !containsString(required, key) {
+			unknown = append(unknown, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "All required Info.plist keys are present.\n\n")
+		}
+	} else {
+		fmt.Fprintf(output, "Missing required Info.plist keys: %d\n", len(missing))
+		fmt.Fprintf(output, "====================\n")
+		for _, key := range missing {
+			fmt.Fprintf(output, "  %s\n", key)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+
+	if len(unknown) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No unknown Info.plist keys found.\n")
+		}
+	} else {
+		fmt.Fprintf(output, "WARNING: Unknown Info.plist keys found: %d\n", len(unknown))
+		fmt.Fprintf(output, "====================\n")
+		for _, key := range unknown {
+			fmt.Fprintf(output, "Key: \"%s\" (line %d)\n", key, result.UniqueEntries[key].LineNum)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+
+	return len(missing) == 0
+}
+
+// checkKeyOrder compares the order of the keys that both files define and
+// reports the first position where the target diverges from the base. Keys
+// missing from either file are skipped so they don't count as a divergence.
+// It returns whether the order matches.
+func checkKeyOrder(output io.Writer, base, target *analyzer.Result, quiet bool) bool {
+	var expected, actual []string
+	for _, key := range base.KeyOrder {
+		if _, exists := target.UniqueEntries[key]; exists {
+			expected = append(expected, key)
+		}
+	}
+	for _, key := range target.KeyOrder {
+		if _, exists := base.UniqueEntries[key]; exists {
+			actual = append(actual, key)
+		}
+	}
+
+	for i := range expected {
+		if expected[i] == actual[i] {
+			continue
+		}
+		fmt.Fprintf(output, "Key order diverges at shared key %d:\n", i+1)
+		fmt.Fprintf(output, "====================\n")
+		fmt.Fprintf(output, "  Expected: \"%s\" (line %d in base)\n", expected[i], base.UniqueEntries[expected[i]].LineNum)
+		fmt.Fprintf(output, "  Actual:   \"%s\" (line %d)\n", actual[i], target.UniqueEntries[actual[i]].LineNum)
+		return false
+	}
+
+	if !quiet {
+		fmt.Fprintf(output, "Key order matches the base file (%d shared keys).\n", len(expected))
+	}
+	return true
+}
+
+func printDiff(output io.Writer, baseFile, otherFile string, diff analyzer.DiffResult, valueDiff string) {
+	fmt.Fprintf(output, "Comparing %s with %s\n", baseFile, otherFile)
+	fmt.Fprintf(output, "====================\n")
+
+	fmt.Fprintf(output, "Added keys: %d\n", len(diff.Added))
+	for _, entry := range diff.Added {
+		fmt.Fprintf(output, "  Line %d: \"%s\" = \"%s\"\n", entry.LineNum, entry.Key, entry.Value)
+	}
+
+	fmt.Fprintf(output, "Removed keys: %d\n", len(diff.Removed))
+	for _, entry := range diff.Removed {
+		fmt.Fprintf(output, "  Line %d: \"%s\" = \"%s\"\n", entry.LineNum, entry.Key, entry.Value)
+	}
+
+	fmt.Fprintf(output, "Changed keys: %d\n", len(diff.Changed))
+	for _, change := range diff.Changed {
+		fmt.Fprintf(output, "  Key: \"%s\"\n", change.Key)
+		fmt.Fprintf(output, "    - \"%s\"\n", change.OldValue)
+		fmt.Fprintf(output, "    + \"%s\"\n", change.NewValue)
+		if valueDiff != "" {
+			fmt.Fprintf(output, "    ~ \"%s\"\n", inlineDiff(change.OldValue, change.NewValue, valueDiff))
+		}
+	}
+
+	if len(diff.Reordered) > 0 {
+		fmt.Fprintf(output, "Equivalent but reordered placeholders: %d\n", len(diff.Reordered))
+		for _, change := range diff.Reordered {
+			fmt.Fprintf(output, "  Key: \"%s\"\n", change.Key)
+			fmt.Fprintf(output, "    - \"%s\"\n", change.OldValue)
+			fmt.Fprintf(output, "    + \"%s\"\n", change.NewValue)
+		}
+	}
+
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 && len(diff.Reordered) == 0 {
+		fmt.Fprintf(output, "The files contain the same keys and values.\n")
+	}
+}
+
+// maxDiffCells bounds the size of the table inlineDiff builds. Values with
+// more token pairs than this are shown as entirely replaced.
+const maxDiffCells = 1000000
+
+// inlineDiff marks what changed between two values in the style of git's
+// --word-diff: removed text as [-text-] and added text as {+text+}. The
+// values are compared word by word, keeping whitespace as separate tokens,
+// or character by character when mode is "char".
+func inlineDiff(oldValue, newValue, mode string) string {
+	split := diffWords
+	if mode == "char" {
+		split = diffChars
+	}
+	a, b := split(oldValue), split(newValue)
+
+	var out strings.Builder
+	if len(a)*len(b) > maxDiffCells {
+		fmt.Fprintf(&out, "[-%s-]{+%s+}", oldValue, newValue)
+		return out.String()
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Collect runs of removed and added tokens and flush them together
+	var removed, added strings.Builder
+	flush := func() {
+		if removed.Len() > 0 {
+			fmt.Fprintf(&out, "[-%s-]", removed.String())
+			removed.Reset()
+		}
+		if added.Len() > 0 {
+			fmt.Fprintf(&out, "{+%s+}", added.String())
+			added.Reset()
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			out.WriteString(a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed.WriteString(a[i])
+			i++
+		default:
+			added.WriteString(b[j])
+			j++
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// diffWords splits a value into words and the whitespace between them
+func diffWords(value string) []string {
+	var tokens []string
+	start := 0
+	inSpace := false
+	for i, r := range value {
+		space := unicode.IsSpace(r)
+		if i > start && space != inSpace {
+			tokens = append(tokens, value[start:i])
+			start = i
+		}
+		inSpace = space
+	}
+	if start < len(value) {
+		tokens = append(tokens, value[start:])
+	}
+	return tokens
+}
+
+// diffChars splits a value into its characters
+func diffChars(value string) []string {
+	tokens := make([]string, 0, len(value))
+	for _, r := range value {
+		tokens = append(tokens, string(r))
+	}
+	return tokens
+}
+
+// markupTagPattern matches opening, closing and self-closing tags like <b>, </b> and <br/>
+var markupTagPattern = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)[^<>]*?(/?)>`)
+
+// findMarkupIssues returns a description of every unbalanced or mismatched
+// tag in value. Only tags listed in tags are considered.
+func findMarkupIssues(value string, tags map[string]bool) []string {
+	var issues []string
+	var open []string
+
+	for _, match := range markupTagPattern.FindAllStringSubmatch(value, -1) {
+		closing := match[1] == "/"
+		name := strings.ToLower(match[2])
+		selfClosing := match[3] == "/"
+
+		if !tags[name] || selfClosing {
+			continue
+		}
+
+		if !closing {
+			open = append(open, name)
+			continue
+		}
+
+		if len(open) == 0 {
+			issues = append(issues, fmt.Sprintf("unexpected closing tag </%s>", name))
+			continue
+		}
+
+		top := open[len(open)-1]
+		open = open[:len(open)-1]
+		if top != name {
+			issues = append(issues, fmt.Sprintf("mismatched tag </%s>, expected </%s>", name, top))
+		}
+	}
+
+	for _, name := range open {
+		issues = append(issues, fmt.Sprintf("unclosed tag <%s>", name))
+	}
+
+	return issues
+}
+
+// closingBrackets maps each opening bracket to its closing counterpart
+var closingBrackets = map[rune]rune{'(': ')', '[': ']', '{': '}'}
+
+// findBracketIssues returns a description of every unbalanced (), [] or {}
+// pair in value. Positions are character offsets in the logical (stored)
+// order, which right-to-left rendering doesn't change.
+func findBracketIssues(value string) []string {
+	type openBracket struct {
+		char     rune
+		position int
+	}
+
+	var issues []string
+	var open []openBracket
+
+	position := 0
+	for _, char := range value {
+		position++
+		switch char {
+		case '(', '[', '{':
+			open = append(open, openBracket{char, position})
+		case ')', ']', '}':
+			if len(open) == 0 {
+				issues = append(issues, fmt.Sprintf("unmatched '%c' at position %d", char, position))
+				continue
+			}
+			top := open[len(open)-1]
+			open = open[:len(open)-1]
+			if closingBrackets[top.char] != char {
+				issues = append(issues, fmt.Sprintf("'%c' at position %d closes '%c' from position %d (expected '%c')",
+					char, position, top.char, top.position, closingBrackets[top.char]))
+			}
+		}
+	}
+
+	for _, bracket := range open {
+		issues = append(issues, fmt.Sprintf("unclosed '%c' at position %d", bracket.char, bracket.position))
+	}
+
+	return issues
+}
+
+// findControlChars describes every control character in value that isn't
+// allowed, with its code point and byte offset
+func findControlChars(value string, allowed map[rune]bool) []string {
+	var issues []string
+	for offset, char := range value {
+		if unicode.IsControl(char) && !allowed[char] {
+			issues = append(issues, fmt.Sprintf("control character U+%04X at byte offset %d", char, offset))
+		}
+	}
+	return issues
+}
+
+// findRepeatedRuns describes every run of the same character in value
+// that is longer than maxRun. Whitespace runs are left alone, since they are
+// usually intentional padding.
+func findRepeatedRuns(value string, maxRun int) []string {
+	var issues []string
+	runes := []rune(value)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && runes[end] == runes[start] {
+			end++
+		}
+		if end-start > maxRun && !unicode.IsSpace(runes[start]) {
+			issues = append(issues, fmt.Sprintf("%q repeated %d times at character %d", runes[start], end-start, start+1))
+		}
+		start = end
+	}
+	return issues
+}
+
+// stripControlChars removes every control character that isn't allowed
+func stripControlChars(value string, allowed map[rune]bool) string {
+	return strings.Map(func(char rune) rune {
+		if unicode.IsControl(char) && !allowed[char] {
+			return -1
+		}
+		return char
+	}, value)
+}
+
+// replaceKey swaps the quoted key on a key-value line
+func replaceKey(line, oldKey, newKey string) string {
+	for _, quote := range []string{"\"", "'"} {
+		quoted := quote + oldKey + quote
+		if index := strings.Index(line, quoted); index >= 0 {
+			return line[:index] + quote + newKey + quote + line[index+len(quoted):]
+		}
+	}
+	return line
+}
+
+// valueReplacer is one -replace-value or -replace-regex edit
+type valueReplacer struct {
+	old     string         // Substring to replace, for -replace-value
+	pattern *regexp.Regexp // Expression to replace, for -replace-regex
+	new     string
+}
+
+// apply returns value with the edit made and the number of replacements
+func (r valueReplacer) apply(value string) (string, int) {
+	if r.pattern != nil {
+		count := len(r.pattern.FindAllStringIndex(value, -1))
+		if count == 0 {
+			return value, 0
+		}
+		return r.pattern.ReplaceAllString(value, r.new), count
+	}
+	count := strings.Count(value, r.old)
+	if count == 0 {
+		return value, 0
+	}
+	return strings.ReplaceAll(value, r.old, r.new), count
+}
+
+// parseValueReplacements parses "old=new" substring replacements followed
+// by "pattern=replacement" regular expression replacements. Both are split
+// at the first "=", so a pattern has to write "=" as \x3D.
+func parseValueReplacements(substrings, regexes []string) ([]valueReplacer, error) {
+	var replacers []valueReplacer
+	for _, replacement := range substrings {
+		oldText, newText, found := strings.Cut(replacement, "=")
+		if !found || oldText == "" {
+			return nil, fmt.Errorf("invalid -replace-value %q, expected old=new", replacement)
+		}
+		replacers = append(replacers, valueReplacer{old: oldText, new: newText})
+	}
+	for _, replacement := range regexes {
+		expr, newText, found := strings.Cut(replacement, "=")
+		if !found || expr == "" {
+			return nil, fmt.Errorf("invalid -replace-regex %q, expected pattern=replacement", replacement)
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -replace-regex pattern: %w", err)
+		}
+		replacers = append(replacers, valueReplacer{pattern: pattern, new: newText})
+	}
+	return replacers, nil
+}
+
+// planValueReplacements applies the replacers in order to the value of
+// every entry of the file itself and returns the new values of the changed
+// entries by line number, along with the total number of replacements.
+// Keys and comments are never touched.
+func planValueReplacements(entries []analyzer.KeyValue, replacers []valueReplacer) (map[int]string, int) {
+	newValues := make(map[int]string)
+	total := 0
+	for _, entry := range entries {
+		if entry.File != "" {
+			continue
+		}
+		value := entry.Value
+		for _, replacer := range replacers {
+			var count int
+			value, count = replacer.apply(value)
+			total += count
+		}
+		if value != entry.Value {
+			newValues[entry.LineNum] = value
+		}
+	}
+	return newValues, total
+}
+
+// reportValueReplacements lists every entry whose value a replacement
+// changed, with the old and the new value
+func reportValueReplacements(output io.Writer, entries []analyzer.KeyValue, newValues map[int]string, replacements int, quiet bool) {
+	if quiet {
+		return
+	}
+	if len(newValues) == 0 {
+		fmt.Fprintf(output, "No values matched the replacements.\n")
+		return
+	}
+
+	fmt.Fprintf(output, "Replaced %d occurrences in %d values:\n", replacements, len(newValues))
+	fmt.Fprintf(output, "====================\n")
+	for _, entry := range entries {
+		if newValue, changed := newValues[entry.LineNum]; changed && entry.File == "" {
+			fmt.Fprintf(output, "Key: \"%s\" (line %d)\n", entry.Key, entry.LineNum)
+			fmt.Fprintf(output, "    - \"%s\"\n", entry.Value)
+			fmt.Fprintf(output, "    + \"%s\"\n", newValue)
+		}
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// planRenames applies "old=new" renames in order to the given keys and
+// returns the resulting mapping from original to final key, along with
+// warnings for renames of missing keys and renames onto existing keys
+func planRenames(keys []string, renames []string) (map[string]string, []string, error) {
+	// current maps each original key to its name after the renames so far
+	current := make(map[string]string)
+	for _, key := range keys {
+		current[key] = key
+	}
+
+	var warnings []string
+	for _, rename := range renames {
+		oldKey, newKey, found := strings.Cut(rename, "=")
+		if !found || oldKey == "" || newKey == "" || strings.Contains(newKey, "\"") {
+			return nil, nil, fmt.Errorf("invalid rename %q, expected old=new", rename)
+		}
+
+		var renamed, existing bool
+		for original, name := range current {
+			switch name {
+			case oldKey:
+				current[original] = newKey
+				renamed = true
+			case newKey:
+				existing = true
+			}
+		}
+
+		if !renamed {
+			warnings = append(warnings, fmt.Sprintf("key \"%s\" not found, rename to \"%s\" skipped", oldKey, newKey))
+		} else if existing {
+			warnings = append(warnings, fmt.Sprintf("key \"%s\" already exists, renaming \"%s\" creates a duplicate", newKey, oldKey))
+		}
+	}
+
+	mapping := make(map[string]string)
+	for original, name := range current {
+		if name != original {
+			mapping[original] = name
+		}
+	}
+
+	return mapping, warnings, nil
+}
+
+// keyWords splits a key into words at "_", ".", "-" and spaces and at
+// camelCase boundaries, keeping acronyms together ("loadURLButton" is
+// load, URL, Button)
+func keyWords(key string) []string {
+	var words []string
+	runes := []rune(key)
+	start := -1
+	for i, r := range runes {
+		if r == '_' || r == '.' || r == '-' || unicode.IsSpace(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// normalizeKey rewrites a key in snake_case, camelCase or dot.case
+func normalizeKey(key, style string) string {
+	words := keyWords(key)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if style == "camelCase" && i > 0 {
+			first, size := utf8.DecodeRuneInString(word)
+			word = string(unicode.ToUpper(first)) + word[size:]
+		}
+		words[i] = word
+	}
+
+	switch style {
+	case "camelCase":
+		return strings.Join(words, "")
+	case "dot.case":
+		return strings.Join(words, ".")
+	}
+	return strings.Join(words, "_")
+}
+
+// planKeyNormalization maps every key whose normalized form differs to that
+// form. Keys that would end up with the same normalized key are collisions:
+// they are all left unchanged and returned by normalized key, so that
+// normalizing never merges two keys.
+func planKeyNormalization(keys []string, style string) (map[string]string, map[string][]string) {
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		normalized := normalizeKey(key, style)
+		groups[normalized] = append(groups[normalized], key)
+	}
+
+	mapping := make(map[string]string)
+	collisions := make(map[string][]string)
+	for normalized, originals := range groups {
+		if len(originals) > 1 {
+			collisions[normalized] = originals
+			continue
+		}
+		if originals[0] != normalized {
+			mapping[originals[0]] = normalized
+		}
+	}
+	return mapping, collisions
+}
+
+// reportNormalizationCollisions lists the keys that -normalize-keys leaves
+// unchanged because they would collide
+func reportNormalizationCollisions(output io.Writer, result *analyzer.Result, collisions map[string][]string, quiet bool) {
+	if len(collisions) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No key normalization collisions found.\n\n")
+		}
+		return
+	}
+
+	var normalizedKeys []string
+	for normalized := range collisions {
+		normalizedKeys = append(normalizedKeys, normalized)
+	}
+	sort.Strings(normalizedKeys)
+
+	fmt.Fprintf(output, "Key normalization collisions found: %d\n", len(collisions))
+	fmt.Fprintf(output, "====================\n")
+	for _, normalized := range normalizedKeys {
+		fmt.Fprintf(output, "Key: \"%s\" would be the normalized form of:\n", normalized)
+		for _, key := range collisions[normalized] {
+			fmt.Fprintf(output, "  \"%s\" (line %d)\n", key, result.UniqueEntries[key].LineNum)
+		}
+	}
+	fmt.Fprintf(output, "These keys are left unchanged.\n\n")
+}
+
+// replaceValue swaps the value of entry on its line at the offsets the
+// parser matched it at, leaving the key, separator and any trailing comment
+// untouched
+func replaceValue(line string, entry analyzer.KeyValue, newValue string) string {
+	if entry.Value == newValue || entry.ValueEnd == 0 || entry.ValueEnd > len(line) {
+		return line
+	}
+	return line[:entry.ValueStart] + newValue + line[entry.ValueEnd:]
+}
+
+// parseCodePoints parses a comma-separated list of hex code points such as
+// "09,U+000B"
+func parseCodePoints(list string) (map[rune]bool, error) {
+	codePoints := make(map[rune]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(item)), "U+")
+		if item == "" {
+			continue
+		}
+		codePoint, err := strconv.ParseUint(item, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid code point %q", item)
+		}
+		codePoints[rune(codePoint)] = true
+	}
+	return codePoints, nil
+}
+
+// reportValueIssues runs check on every value and lists the entries it
+// finds issues in under the given title, e.g. "Markup issues"
+func reportValueIssues(output io.Writer, title string, entries []analyzer.KeyValue, check func(value string) []string, quiet bool) {
+	var found []analyzer.KeyValue
+	issuesByLine := make(map[int][]string)
+
+	for _, entry := range entries {
+		if issues := check(entry.Value); len(issues) > 0 {
+			found = append(found, entry)
+			issuesByLine[entry.LineNum] = issues
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No %s found.\n", strings.ToLower(title))
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "%s found: %d\n", title, len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, entry := range found {
+		fmt.Fprintf(output, "Key: \"%s\" (line %d)\n", entry.Key, entry.LineNum)
+		fmt.Fprintf(output, "  Value: \"%s\"\n", entry.Value)
+		for _, issue := range issuesByLine[entry.LineNum] {
+			fmt.Fprintf(output, "  - %s\n", issue)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+}
+
+// dropIgnoredKeys returns the duplicate groups whose key isn't in ignored
+func dropIgnoredKeys(duplicateKeys map[string][]analyzer.KeyValue, ignored map[string]bool) map[string][]analyzer.KeyValue {
+	if len(ignored) == 0 {
+		return duplicateKeys
+	}
+	kept := make(map[string][]analyzer.KeyValue)
+	for key, entries := range duplicateKeys {
+		if !ignored[key] {
+			kept[key] = entries
+		}
+	}
+	return kept
+}
+
+// readKeyList reads a newline-separated list of keys. Blank lines and
+// lines starting with # are skipped.
+func readKeyList(filename string) (map[string]bool, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	keys := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key := strings.TrimSpace(scanner.Text())
+		if key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		keys[key] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning file: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Minimal SARIF 2.1.0 structures, see https://docs.oasis-open.org/sarif/sarif/v2.1.0/
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// writeSARIF reports every repeated occurrence of a duplicate key (pointing
+// back at the first occurrence) and every malformed line as a SARIF result.
+// Conflicting duplicates are errors; safe duplicates, quote-style-only
+// differences and malformed lines are warnings.
+func writeSARIF(filename, inputFile string, duplicateKeys map[string][]analyzer.KeyValue, malformedLines []int, rawLines []string) error {
+	uri := filepath.ToSlash(inputFile)
+	location := func(file string, lineNum int) sarifLocation {
+		if file == "" {
+			file = uri
+		}
+		return sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(file)},
+			Region:           sarifRegion{StartLine: lineNum},
+		}}
+	}
+
+	results := []sarifResult{}
+
+	var keys []string
+	for key := range duplicateKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entries := duplicateKeys[key]
+		first := entries[0]
+		quoteStyle := quoteStyleOnly(entries)
+
+		for _, entry := range entries[1:] {
+			// Name the file of the first definition when it is in another
+			// file, such as an #include
+			where := fmt.Sprintf("line %d", first.LineNum)
+			if first.File != entry.File {
+				file := first.File
+				if file == "" {
+					file = inputFile
+				}
+				where = fmt.Sprintf("line %d of %s", first.LineNum, filepath.ToSlash(file))
+			}
+
+			result := sarifResult{
+				RuleID:           "duplicate-key",
+				Level:            "warning",
+				Message:          sarifMessage{Text: fmt.Sprintf("Key \"%s\" is already defined at %s with the same value.", key, where)},
+				Locations:        []sarifLocation{location(entry.File, entry.LineNum)},
+				RelatedLocations: []sarifLocation{location(first.File, first.LineNum)},
+			}
+			if entry.Value != first.Value && quoteStyle {
+				result.RuleID = "quote-style-duplicate-key"
+				result.Message.Text = fmt.Sprintf("Key \"%s\" is already defined at %s with a value that differs only in quote style.", key, where)
+			} else if entry.Value != first.Value {
+				result.RuleID = "conflicting-duplicate-key"
+				result.Level = "error"
+				result.Message.Text = fmt.Sprintf("Key \"%s\" is already defined at %s with a different value (\"%s\" vs \"%s\").",
+					key, where, first.Value, entry.Value)
+			}
+			results = append(results, result)
+		}
+	}
+
+	for _, lineNum := range malformedLines {
+		results = append(results, sarifResult{
+			RuleID:    "malformed-line",
+			Level:     "warning",
+			Message:   sarifMessage{Text: fmt.Sprintf("Line is not a comment or a \"key\" = \"value\"; entry: %s", strings.TrimSpace(rawLines[lineNum-1]))},
+			Locations: []sarifLocation{location("", lineNum)},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "localization-analyzer",
+				InformationURI: "https://github.com/zhirnovvlad/localization-string-analyzer",
+				Rules: []sarifRule{
+					{ID: "duplicate-key", ShortDescription: sarifMessage{Text: "Key is defined more than once with the same value"}},
+					{ID: "quote-style-duplicate-key", ShortDescription: sarifMessage{Text: "Key is defined more than once with values that differ only in quote style"}},
+					{ID: "conflicting-duplicate-key", ShortDescription: sarifMessage{Text: "Key is defined more than once with different values"}},
+					{ID: "malformed-line", ShortDescription: sarifMessage{Text: "Line is not a comment or a key-value pair"}},
+				},
+			}},
+			Results: results,
+		}},
+	}
+
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode SARIF report: %w", err)
+	}
+
+	if err := os.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write SARIF report: %w", err)
+	}
+
+	return nil
+}
+
+// markdownCollapseRows is the table size above which a Markdown section is
+// wrapped in a collapsible <details> block
+const markdownCollapseRows = 10
+
+// markdownCell escapes a value for use inside a Markdown table cell
+var markdownCell = strings.NewReplacer("|", "\\|", "`", "'", "\n", " ")
+
+// writeMarkdownReport writes the duplicate keys as Markdown tables, one for
+// conflicts and one for duplicates with the same value, under a one-line
+// summary. Locations are plain file:line text so they link well in reviews.
+func writeMarkdownReport(filename, inputFile string, duplicateKeys map[string][]analyzer.KeyValue, sortBy string) error {
+	var conflicts, duplicates []string
+	for _, key := range sortDuplicateKeys(duplicateKeys, sortBy) {
+		if isConflict(duplicateKeys[key]) {
+			conflicts = append(conflicts, key)
+		} else {
+			duplicates = append(duplicates, key)
+		}
+	}
+
+	location := func(entry analyzer.KeyValue) string {
+		if entry.File != "" {
+			return fmt.Sprintf("%s:%d", entry.File, entry.LineNum)
+		}
+		return fmt.Sprintf("%s:%d", inputFile, entry.LineNum)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Localization report for `%s`\n\n", inputFile)
+	if len(duplicateKeys) == 0 {
+		fmt.Fprintf(&b, "✅ No duplicate keys\n")
+	} else {
+		fmt.Fprintf(&b, "⚠️ %d conflicts, %d duplicates\n", len(conflicts), len(duplicates))
+	}
+
+	writeSection := func(title string, keys []string, header string, row func(key string) string) {
+		if len(keys) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n")
+		collapse := len(keys) > markdownCollapseRows
+		if collapse {
+			fmt.Fprintf(&b, "<details>\n<summary>%s (%d)</summary>\n\n", title, len(keys))
+		} else {
+			fmt.Fprintf(&b, "### %s (%d)\n\n", title, len(keys))
+		}
+		fmt.Fprintf(&b, "%s\n", header)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "%s\n", row(key))
+		}
+		if collapse {
+			fmt.Fprintf(&b, "\n</details>\n")
+		}
+	}
+
+	writeSection("Conflicts", conflicts, "| Key | Location | Value |\n| --- | --- | --- |", func(key string) string {
+		var rows []string
+		for i, entry := range duplicateKeys[key] {
+			keyCell := ""
+			if i == 0 {
+				keyCell = "`" + markdownCell.Replace(key) + "`"
+			}
+			rows = append(rows, fmt.Sprintf("| %s | %s | %s |", keyCell, location(entry), markdownCell.Replace(entry.Value)))
+		}
+		return strings.Join(rows, "\n")
+	})
+
+	writeSection("Duplicates", duplicates, "| Key | Occurrences | Locations |\n| --- | --- | --- |", func(key string) string {
+		entries := duplicateKeys[key]
+		var locations []string
+		for _, entry := range entries {
+			locations = append(locations, location(entry))
+		}
+		return fmt.Sprintf("| `%s` | %d | %s |", markdownCell.Replace(key), len(entries), strings.Join(locations, ", "))
+	})
+
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
+
+// reportLongValues lists every entry whose value is longer than maxLen
+// characters and returns how many were found. Length is counted in runes
+// so accented characters and emoji count as one character each.
+func reportLongValues(output io.Writer, entries []analyzer.KeyValue, maxLen int, quiet bool) int {
+	var violations []analyzer.KeyValue
+	for _, entry := range entries {
+		if utf8.RuneCountInString(entry.Value) > maxLen {
+			violations = append(violations, entry)
+		}
+	}
+
+	if len(violations) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No values longer than %d characters.\n", maxLen)
+		}
+		return 0
+	}
+
+	fmt.Fprintf(output, "Values longer than %d characters: %d\n", maxLen, len(violations))
+	fmt.Fprintf(output, "====================\n")
+	for _, entry := range violations {
+		fmt.Fprintf(output, "Key: \"%s\" (line %d) has %d characters:\n", entry.Key, entry.LineNum, utf8.RuneCountInString(entry.Value))
+		fmt.Fprintf(output, "  Value: \"%s\"\n", entry.Value)
+		fmt.Fprintf(output, "\n")
+	}
+
+	return len(violations)
+}
+
+// graphemeCount counts the grapheme clusters of s, the units users perceive
+// as characters, following Unicode text segmentation (UAX #29)
+func graphemeCount(s string) int {
+	return uniseg.GraphemeClusterCount(s)
+}
+
+// printValueStats prints how long the values are in bytes, runes and
+// grapheme clusters. The three differ for accented text and emoji such as
+// 👨‍👩‍👧, which is 18 bytes, 5 runes and a single grapheme. With perValue,
+// every value whose grapheme count differs from its rune count is listed;
+// with maxGraphemes above zero, values longer than that are flagged.
+func printValueStats(output io.Writer, entries []analyzer.KeyValue, perValue bool, maxGraphemes int, quiet bool) {
+	var bytesTotal, runesTotal, graphemesTotal int
+	var differing, tooLong []analyzer.KeyValue
+	for _, entry := range entries {
+		runes := utf8.RuneCountInString(entry.Value)
+		graphemes := graphemeCount(entry.Value)
+		bytesTotal += len(entry.Value)
+		runesTotal += runes
+		graphemesTotal += graphemes
+		if graphemes != runes {
+			differing = append(differing, entry)
+		}
+		if maxGraphemes > 0 && graphemes > maxGraphemes {
+			tooLong = append(tooLong, entry)
+		}
+	}
+
+	fmt.Fprintf(output, "Value statistics:\n")
+	fmt.Fprintf(output, "====================\n")
+	fmt.Fprintf(output, "  Values: %d\n", len(entries))
+	fmt.Fprintf(output, "  Bytes: %d\n", bytesTotal)
+	fmt.Fprintf(output, "  Runes: %d\n", runesTotal)
+	fmt.Fprintf(output, "  Grapheme clusters: %d\n", graphemesTotal)
+	fmt.Fprintf(output, "  Values with multi-rune graphemes: %d\n", len(differing))
+	fmt.Fprintf(output, "\n")
+
+	if perValue && len(differing) > 0 {
+		fmt.Fprintf(output, "Values whose grapheme count differs from their rune count: %d\n", len(differing))
+		fmt.Fprintf(output, "====================\n")
+		table := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(table, "Line\tKey\tBytes\tRunes\tGraphemes\n")
+		for _, entry := range differing {
+			fmt.Fprintf(table, "%d\t%s\t%d\t%d\t%d\n", entry.LineNum, entry.Key,
+				len(entry.Value), utf8.RuneCountInString(entry.Value), graphemeCount(entry.Value))
+		}
+		table.Flush()
+		fmt.Fprintf(output, "\n")
+	}
+
+	if maxGraphemes <= 0 {
+		return
+	}
+	if len(tooLong) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No values longer than %d grapheme clusters.\n\n", maxGraphemes)
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Values longer than %d grapheme clusters: %d\n", maxGraphemes, len(tooLong))
+	fmt.Fprintf(output, "====================\n")
+	for _, entry := range tooLong {
+		fmt.Fprintf(output, "Key: \"%s\" (line %d) has %d grapheme clusters:\n", entry.Key, entry.LineNum, graphemeCount(entry.Value))
+		fmt.Fprintf(output, "  Value: \"%s\"\n", entry.Value)
+		fmt.Fprintf(output, "\n")
+	}
+}
+
+// outputDirPath returns where -output-dir writes the cleaned copy of the
+// file at relPath, relative to the scanned directory or archive. Paths that
+// would end up outside dir, such as "../x.strings" inside an archive, are
+// rejected.
+func outputDirPath(dir, relPath string) (string, error) {
+	relPath = filepath.Clean(relPath)
+	if filepath.IsAbs(relPath) || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the scanned directory", relPath)
+	}
+	return filepath.Join(dir, relPath), nil
+}
+
+// splitDefaultFile is the -split file for keys without a namespace
+const splitDefaultFile = "Default.strings"
+
+// keyNamespace returns the part of key before the first ".", or "" when the
+// key has no namespace or it can't be used as a file name
+func keyNamespace(key string) string {
+	idx := strings.Index(key, ".")
+	if idx <= 0 {
+		return ""
+	}
+	namespace := key[:idx]
+	if strings.ContainsAny(namespace, `/\:`) {
+		return ""
+	}
+	return namespace
+}
+
+// splitByNamespace writes the first occurrence of every key, with the
+// comment lines above it, to <namespace>.strings in dir, and keys without a
+// namespace to splitDefaultFile. Entries keep their file order. It returns
+// the number of files created and of keys without a namespace. A namespace
+// whose file would be splitDefaultFile, such as "Default", is an error when
+// there are keys without a namespace, rather than merging the two groups.
+func splitByNamespace(dir string, result *analyzer.Result) (int, int, error) {
+	var names []string
+	contents := make(map[string]*strings.Builder)
+	withoutNamespace := 0
+	collidingKey := ""
+	for _, key := range result.KeysInOrder() {
+		entry := result.UniqueEntries[key]
+		name := splitDefaultFile
+		if namespace := keyNamespace(key); namespace != "" {
+			name = namespace + ".strings"
+			// Compared without case, as the file systems of macOS do
+			if strings.EqualFold(name, splitDefaultFile) && collidingKey == "" {
+				collidingKey = key
+			}
+		} else {
+			withoutNamespace++
+		}
+
+		content, exists := contents[name]
+		if !exists {
+			content = &strings.Builder{}
+			contents[name] = content
+			names = append(names, name)
+		} else if entry.CommentLine != 0 && entry.File == "" {
+			// Keep a commented entry visually apart from the one before
+			content.WriteString("\n")
+		}
+
+		// Entries from #include files have no raw lines here, so they are
+		// written in canonical form
+		if entry.File != "" {
+			fmt.Fprintf(content, "\"%s\" = \"%s\";\n", entry.Key, entry.Value)
+			continue
+		}
+		first := entry.LineNum
+		if entry.CommentLine != 0 {
+			first = entry.CommentLine
+		}
+		for _, line := range result.RawLines[first-1 : entry.LineNum] {
+			content.WriteString(line)
+			content.WriteString("\n")
+		}
+	}
+
+	if collidingKey != "" && withoutNamespace > 0 {
+		return 0, 0, fmt.Errorf("key %q has a namespace that would be written to %s together with the %d keys without a namespace", collidingKey, splitDefaultFile, withoutNamespace)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents[name].String()), 0644); err != nil {
+			return 0, 0, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return len(names), withoutNamespace, nil
+}
+
+// exportEntries writes the first occurrence of every key to a JSON or CSV
+// file, chosen by the file extension. Entries keep their file order unless
+// sortByKey is set.
+func exportEntries(filename string, result *analyzer.Result, sortByKey bool) error {
+	keys := result.KeysInOrder()
+	if sortByKey {
+		keys = result.SortedKeys()
+	}
+
+	entries := make([]analyzer.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, result.UniqueEntries[key])
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		type jsonEntry struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+			Line  int    `json:"line"`
+		}
+
+		jsonEntries := make([]jsonEntry, 0, len(entries))
+		for _, entry := range entries {
+			jsonEntries = append(jsonEntries, jsonEntry{Key: entry.Key, Value: entry.Value, Line: entry.LineNum})
+		}
+
+		if err := writeJSON(file, jsonEntries); err != nil {
+			return fmt.Errorf("failed to write JSON: %w", err)
+		}
+	case ".csv":
+		writer := csv.NewWriter(file)
+		writer.Write([]string{"key", "value", "line"})
+		for _, entry := range entries {
+			writer.Write([]string{entry.Key, entry.Value, strconv.Itoa(entry.LineNum)})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported export format %q (use .json or .csv)", filepath.Ext(filename))
+	}
+
+	return nil
+}
+
+// inProgressWindow is how many lines above an active entry a commented-out
+// entry may be for reportCommentedKeys to flag a possible in-progress override
+const inProgressWindow = 3
+
+// reportCommentedKeys lists keys that appear in a // comment and are also
+// defined on an active line, which usually means a stale override was left behind
+func reportCommentedKeys(output io.Writer, result *analyzer.Result, quiet bool) {
+	commented := make(map[string][]analyzer.KeyValue)
+	for _, entry := range result.CommentedEntries {
+		if _, active := result.UniqueEntries[entry.Key]; active {
+			commented[entry.Key] = append(commented[entry.Key], entry)
+		}
+	}
+
+	if len(commented) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No commented-out keys match active keys.\n")
+		}
+		return
+	}
+
+	var keys []string
+	for key := range commented {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(output, "Commented-out keys that are also active: %d\n", len(keys))
+	fmt.Fprintf(output, "====================\n")
+	for _, key := range keys {
+		fmt.Fprintf(output, "Key: \"%s\"\n", key)
+
+		// A different commented value right above the active one usually
+		// means someone is in the middle of changing it
+		for _, comment := range commented[key] {
+			for _, entry := range result.Entries {
+				if entry.Key == key && entry.File == comment.File && entry.Value != comment.Value &&
+					entry.LineNum > comment.LineNum && entry.LineNum-comment.LineNum <= inProgressWindow {
+					fmt.Fprintf(output, "  NOTE: Possible in-progress override: line %d (commented) has \"%s\", line %d has \"%s\"\n",
+						comment.LineNum, comment.Value, entry.LineNum, entry.Value)
+				}
+			}
+		}
+
+		fmt.Fprintf(output, "  Found at lines:\n")
+		for _, entry := range commented[key] {
+			fmt.Fprintf(output, "    Line %d (commented): \"%s\"\n", entry.LineNum, entry.Value)
+		}
+		for _, entry := range result.Entries {
+			if entry.Key == key {
+				fmt.Fprintf(output, "    Line %d: \"%s\"\n", entry.LineNum, entry.Value)
+			}
+		}
+		fmt.Fprintf(output, "\n")
+	}
+}
+
+// coverageReport describes how much of a base file is translated in a target file
+type coverageReport struct {
+	BaseFile          string  `json:"baseFile"`
+	File              string  `json:"file"`
+	BaseKeys          int     `json:"baseKeys"`
+	Translated        int     `json:"translated"`
+	Untranslated      int     `json:"untranslated"`
+	Missing           int     `json:"missing"`
+	PercentTranslated float64 `json:"percentTranslated"`
+}
+
+// computeCoverage counts base keys that are missing from the target, present
+// with the same value as the base (untranslated), or present with a different
+// value (translated)
+func computeCoverage(base, target *analyzer.Result) coverageReport {
+	var coverage coverageReport
+
+	for key, baseEntry := range base.UniqueEntries {
+		coverage.BaseKeys++
+		entry, exists := target.UniqueEntries[key]
+		switch {
+		case !exists || entry.Value == "":
+			coverage.Missing++
+		case entry.Value == baseEntry.Value:
+			coverage.Untranslated++
+		default:
+			coverage.Translated++
+		}
+	}
+
+	if coverage.BaseKeys > 0 {
+		coverage.PercentTranslated = float64(coverage.Translated) / float64(coverage.BaseKeys) * 100
+	}
+
+	return coverage
+}
+
+func printCoverage(output io.Writer, coverage coverageReport) {
+	fmt.Fprintf(output, "Translation coverage of %s relative to %s\n", coverage.File, coverage.BaseFile)
+	fmt.Fprintf(output, "====================\n")
+	fmt.Fprintf(output, "Base Keys: %d\n", coverage.BaseKeys)
+	fmt.Fprintf(output, "Translated: %d\n", coverage.Translated)
+	fmt.Fprintf(output, "Untranslated (same as base): %d\n", coverage.Untranslated)
+	fmt.Fprintf(output, "Missing: %d\n", coverage.Missing)
+	fmt.Fprintf(output, "Coverage: %.1f%%\n", coverage.PercentTranslated)
+}
+
+// jsonReport is the -json form of the duplicate report
+type jsonReport struct {
+	File           string           `json:"file"`
+	TotalEntries   int              `json:"totalEntries"`
+	UniqueKeys     int              `json:"uniqueKeys"`
+	DuplicateBytes int              `json:"duplicateBytes"`
+	DuplicateKeys  []jsonDuplicate  `json:"duplicateKeys"`
+	EmptyKeys      []jsonOccurrence `json:"emptyKeys,omitempty"`
+	Metadata       []metadataEntry  `json:"metadata,omitempty"`
+}
+
+type jsonDuplicate struct {
+	Key                 string           `json:"key"`
+	ExactDuplicate      bool             `json:"exactDuplicate"`
+	Conflict            bool             `json:"conflict"`
+	QuoteStyleOnly      bool             `json:"quoteStyleOnly"`
+	PlaceholderMismatch bool             `json:"placeholderMismatch"`
+	Occurrences         []jsonOccurrence `json:"occurrences"`
+}
+
+type jsonOccurrence struct {
+	Line    int    `json:"line"`
+	Value   string `json:"value"`
+	Section string `json:"section,omitempty"`
+}
+
+func buildJSONReport(inputFile string, result *analyzer.Result, duplicateKeys map[string][]analyzer.KeyValue, sortBy string) jsonReport {
+	report := jsonReport{
+		File:           inputFile,
+		TotalEntries:   len(result.Entries),
+		UniqueKeys:     len(result.UniqueEntries),
+		DuplicateKeys:  []jsonDuplicate{},
+		DuplicateBytes: duplicateBytes(duplicateKeys, result.RawLines),
+	}
+
+	keys := sortDuplicateKeys(duplicateKeys, sortBy)
+
+	for _, key := range keys {
+		entries := duplicateKeys[key]
+		duplicate := jsonDuplicate{Key: key, ExactDuplicate: isExactDuplicate(entries)}
+		if !allValuesSame(entries) {
+			duplicate.QuoteStyleOnly = quoteStyleOnly(entries)
+			duplicate.Conflict = isConflict(entries)
+			duplicate.PlaceholderMismatch = duplicate.Conflict && placeholderCountsDiffer(entries)
+		}
+		for _, entry := range entries {
+			duplicate.Occurrences = append(duplicate.Occurrences, jsonOccurrence{Line: entry.LineNum, Value: entry.Value, Section: entry.Section})
+		}
+		report.DuplicateKeys = append(report.DuplicateKeys, duplicate)
+	}
+
+	for _, entry := range result.EmptyKeys {
+		report.EmptyKeys = append(report.EmptyKeys, jsonOccurrence{Line: entry.LineNum, Value: entry.Value})
+	}
+
+	return report
+}
+
+// jsonReportSchema returns the JSON Schema of the -json report. It is
+// derived from the report structs with the same json tags encoding/json
+// uses, so it always matches what buildJSONReport serializes.
+func jsonReportSchema() map[string]interface{} {
+	schema := jsonSchemaFor(reflect.TypeOf(jsonReport{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "localization-analyzer -json report"
+	return schema
+}
+
+// jsonSchemaFor describes how encoding/json serializes values of type t.
+// Fields tagged omitempty are optional, all others are required.
+func jsonSchemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Ptr:
+		return jsonSchemaFor(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = jsonSchemaFor(field.Type)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+func writeJSON(output io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// scanCache holds parse results from an earlier directory scan, keyed by
+// file path. An entry is reused only while the file's size and modification
+// time are unchanged, and the whole cache is dropped when the parse options
+// differ from the ones it was built with. The raw lines aren't kept, which
+// is why the cache isn't used for reports that show them, and the unique
+// entries, key order and duplicate groups are rebuilt from the entries.
+// Files a scan doesn't come across, such as deleted ones, are dropped when
+// it is saved. BenchmarkDirScan measures a cached scan against parsing
+// every file.
+type scanCache struct {
+	Options string
+	Files   map[string]cachedScanEntry
+	hits    int             // Results reused during this run
+	seen    map[string]bool // Files analyzed during this run
+}
+
+type cachedScanEntry struct {
+	Size    int64
+	ModTime time.Time
+	Result  *analyzer.Result
+}
+
+// newScanCache returns an empty cache for the given parse options
+func newScanCache(opts analyzer.ParseOptions) *scanCache {
+	return &scanCache{
+		Options: cacheOptionsKey(opts),
+		Files:   make(map[string]cachedScanEntry),
+	}
+}
+
+// loadScanCache reads a cache written by save. A missing file, or one built
+// with different parse options, gives an empty cache.
+func loadScanCache(filename string, opts analyzer.ParseOptions) (*scanCache, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return newScanCache(opts), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cache scanCache
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(&cache); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if cache.Options != cacheOptionsKey(opts) || cache.Files == nil {
+		return newScanCache(opts), nil
+	}
+	return &cache, nil
+}
+
+// analyze returns the cached result for filename if the file hasn't
+// changed, and parses it (updating the cache) otherwise
+func (c *scanCache) analyze(filename string, opts analyzer.ParseOptions) (*analyzer.Result, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	c.seen[filename] = true
+
+	if cached, ok := c.Files[filename]; ok && cached.Result != nil &&
+		cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+		c.hits++
+		result := *cached.Result
+		return indexEntries(&result), nil
+	}
+
+	result, err := analyzeLocalizationFile(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.Files[filename] = cachedScanEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Result: &analyzer.Result{
+			Entries:        result.Entries,
+			MalformedLines: result.MalformedLines,
+			EmptyKeys:      result.EmptyKeys,
+			TrailingLines:  result.TrailingLines,
+
+			CommentedEntries: result.CommentedEntries,
+			Encoding:         result.Encoding,
+			FallbackLines:    result.FallbackLines,
+			LineEndings:      result.LineEndings,
+			OrphanedComments: result.OrphanedComments,
+			Format:           result.Format,
+		},
+	}
+	return result, nil
+}
+
+// save writes the cache to filename, going through a temporary file so an
+// interrupted run never leaves a truncated cache behind. Only files
+// analyzed during this run are kept.
+func (c *scanCache) save(filename string) error {
+	for path := range c.Files {
+		if !c.seen[path] {
+			delete(c.Files, path)
+		}
+	}
+
+	tmpFile := filename + ".tmp"
+	file, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if err := gob.NewEncoder(writer).Encode(c); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+// indexEntries fills in the unique entries, key order and duplicate groups
+// of a cached result from its entries, which are in line order
+func indexEntries(result *analyzer.Result) *analyzer.Result {
+	result.UniqueEntries = make(map[string]analyzer.KeyValue)
+	result.DuplicateKeys = make(map[string][]analyzer.KeyValue)
+	result.KeyOrder = nil
+
+	occurrences := make(map[string][]analyzer.KeyValue)
+	for _, entry := range result.Entries {
+		if _, exists := result.UniqueEntries[entry.Key]; !exists {
+			result.UniqueEntries[entry.Key] = entry
+			result.KeyOrder = append(result.KeyOrder, entry.Key)
+		}
+		occurrences[entry.Key] = append(occurrences[entry.Key], entry)
+	}
+	for key, group := range occurrences {
+		if len(group) > 1 {
+			result.DuplicateKeys[key] = group
+		}
+	}
+	return result
+}
+
+// cacheOptionsKey describes the parse options that affect a Result. It
+// lists every option, so a new one can't be forgotten here.
+func cacheOptionsKey(opts analyzer.ParseOptions) string {
+	return fmt.Sprintf("%+v", opts)
+}
+
+// languageStats sums up the .strings files of one language in a directory scan
+type languageStats struct {
+	Language    string
+	Files       int
+	Entries     int
+	UniqueKeys  int
+	Duplicates  int
+	EmptyValues int
+
+	// Keys seen in this language, qualified by file name so that keys of
+	// Localizable.strings and InfoPlist.strings are counted separately
+	keys map[string]bool
+}
+
+// add adds the result of one file to the language totals
+func (s *languageStats) add(path string, result *analyzer.Result, separator, quote string) {
+	s.Files++
+	s.Entries += len(result.Entries)
+	s.UniqueKeys += len(result.KeyOrder)
+	s.Duplicates += len(result.DuplicateKeys)
+	s.EmptyValues += countEmptyValues(result, separator, quote)
+
+	name := filepath.Base(path)
+	for _, key := range result.KeyOrder {
+		s.keys[name+"\x00"+key] = true
+	}
+}
+
+// findCaseCollisions groups entries whose keys differ only in case and come
+// from more than one file, such as "OK" in one table and "ok" in another.
+// On a case-insensitive file system, or once the files are merged, these
+// can resolve to the same string. Groups are in the order their first key
+// appears.
+func findCaseCollisions(entries []analyzer.KeyValue) [][]analyzer.KeyValue {
+	var order []string
+	groups := make(map[string][]analyzer.KeyValue)
+	for _, entry := range entries {
+		folded := strings.ToLower(entry.Key)
+		if _, exists := groups[folded]; !exists {
+			order = append(order, folded)
+		}
+		groups[folded] = append(groups[folded], entry)
+	}
+
+	var collisions [][]analyzer.KeyValue
+	for _, folded := range order {
+		group := groups[folded]
+		spellings := make(map[string]bool)
+		files := make(map[string]bool)
+		for _, entry := range group {
+			spellings[entry.Key] = true
+			files[entry.File] = true
+		}
+		if len(spellings) > 1 && len(files) > 1 {
+			collisions = append(collisions, group)
+		}
+	}
+	return collisions
+}
+
+// reportCaseCollisions lists the groups found by findCaseCollisions with the
+// file and line of every key
+func reportCaseCollisions(output io.Writer, collisions [][]analyzer.KeyValue, quiet bool) {
+	if len(collisions) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No case-insensitive key collisions across files found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "WARNING: Case-insensitive key collisions across files found: %d\n", len(collisions))
+	fmt.Fprintf(output, "====================\n")
+	for _, group := range collisions {
+		fmt.Fprintf(output, "Keys differing only in case:\n")
+		for _, entry := range group {
+			fmt.Fprintf(output, "  \"%s\" in %s (line %d)\n", entry.Key, entry.File, entry.LineNum)
+		}
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// placeholderVerbClasses groups printf conversions by the type of argument
+// they read. Using a different class for the same argument, such as %d in
+// one language and %@ in another, crashes or garbles the string at runtime.
+var placeholderVerbClasses = map[string]string{
+	"d": "int", "D": "int", "i": "int", "u": "int", "U": "int",
+	"x": "int", "X": "int", "o": "int", "O": "int",
+	"f": "float", "F": "float", "e": "float", "E": "float",
+	"g": "float", "G": "float", "a": "float", "A": "float",
+	"@": "object", "s": "c-string", "S": "c-string",
+	"c": "char", "C": "char", "p": "pointer",
+}
+
+// placeholderSignature returns the printf specifiers of value in argument
+// order, as written, and the type classes they read. Positional specifiers
+// are ordered by their position and the others take the next argument, the
+// same way they are matched to the arguments when the string is formatted.
+func placeholderSignature(value string) (string, string) {
+	byArgument := make(map[int]analyzer.Token)
+	next := 1
+	for _, token := range analyzer.ExtractTokens(value) {
+		if token.Kind != analyzer.TokenPrintf {
+			continue
+		}
+		argument := token.Position
+		if argument == 0 {
+			argument = next
+		}
+		byArgument[argument] = token
+		next = argument + 1
+	}
+
+	arguments := make([]int, 0, len(byArgument))
+	for argument := range byArgument {
+		arguments = append(arguments, argument)
+	}
+	sort.Ints(arguments)
+
+	var texts, classes []string
+	for _, argument := range arguments {
+		token := byArgument[argument]
+		texts = append(texts, token.Text)
+		classes = append(classes, fmt.Sprintf("%d:%s", argument, placeholderVerbClasses[token.Verb]))
+	}
+	if len(texts) == 0 {
+		return "(no placeholders)", ""
+	}
+	return strings.Join(texts, " "), strings.Join(classes, " ")
+}
+
+// reportPlaceholderTypeMismatches lists keys whose placeholders read
+// different argument types in different languages, with the placeholder
+// signature of every language. Keys are matched per file name, as with
+// -check-untranslated; files outside an xx.lproj directory are skipped. It
+// returns the number of keys reported.
+func reportPlaceholderTypeMismatches(output io.Writer, languageValues map[string]map[string]analyzer.KeyValue, quiet bool) int {
+	var languages []string
+	qualifiedKeySet := make(map[string]bool)
+	for language, values := range languageValues {
+		if language == "unknown" {
+			continue
+		}
+		languages = append(languages, language)
+		for qualifiedKey := range values {
+			qualifiedKeySet[qualifiedKey] = true
+		}
+	}
+	sort.Strings(languages)
+
+	var qualifiedKeys []string
+	for qualifiedKey := range qualifiedKeySet {
+		qualifiedKeys = append(qualifiedKeys, qualifiedKey)
+	}
+	sort.Strings(qualifiedKeys)
+
+	type languageSignature struct {
+		language  string
+		signature string
+		entry     analyzer.KeyValue
+	}
+	type mismatch struct {
+		key        string
+		signatures []languageSignature
+	}
+	var found []mismatch
+
+	for _, qualifiedKey := range qualifiedKeys {
+		var signatures []languageSignature
+		classes := make(map[string]bool)
+		for _, language := range languages {
+			entry, exists := languageValues[language][qualifiedKey]
+			if !exists {
+				continue
+			}
+			signature, class := placeholderSignature(entry.Value)
+			signatures = append(signatures, languageSignature{language, signature, entry})
+			classes[class] = true
+		}
+		if len(classes) > 1 {
+			key := qualifiedKey[strings.IndexByte(qualifiedKey, 0)+1:]
+			found = append(found, mismatch{key: key, signatures: signatures})
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No placeholder type mismatches between languages found.\n\n")
+		}
+		return 0
+	}
+
+	fmt.Fprintf(output, "CRITICAL: Placeholder type mismatches between languages found: %d\n", len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, f := range found {
+		fmt.Fprintf(output, "Key: \"%s\"\n", f.key)
+		for _, s := range f.signatures {
+			fmt.Fprintf(output, "  %s: %s (line %d in %s)\n", s.language, s.signature, s.entry.LineNum, s.entry.File)
+		}
+	}
+	fmt.Fprintf(output, "\n")
+	return len(found)
+}
+
+// reportIdenticalToBase lists keys whose value is byte-identical to the base
+// language in every other language that defines them, which usually means
+// the key was never translated. Keys in allowed (brand names and the like)
+// are skipped, as are files outside an xx.lproj directory.
+func reportIdenticalToBase(output io.Writer, languageValues map[string]map[string]analyzer.KeyValue, baseLanguage string, allowed map[string]bool, quiet bool) {
+	base := languageValues[baseLanguage]
+	var others []string
+	for language := range languageValues {
+		if language != baseLanguage && language != "unknown" {
+			others = append(others, language)
+		}
+	}
+	sort.Strings(others)
+
+	var qualifiedKeys []string
+	for qualifiedKey := range base {
+		qualifiedKeys = append(qualifiedKeys, qualifiedKey)
+	}
+	sort.Strings(qualifiedKeys)
+
+	type identicalKey struct {
+		entry     analyzer.KeyValue
+		key       string
+		languages []string
+	}
+	var found []identicalKey
+
+	for _, qualifiedKey := range qualifiedKeys {
+		entry := base[qualifiedKey]
+		key := qualifiedKey[strings.IndexByte(qualifiedKey, 0)+1:]
+		if allowed[key] {
+			continue
+		}
+
+		var same []string
+		translated := false
+		for _, language := range others {
+			other, exists := languageValues[language][qualifiedKey]
+			if !exists {
+				continue
+			}
+			if other.Value != entry.Value {
+				translated = true
+				break
+			}
+			same = append(same, language)
+		}
+		if !translated && len(same) > 0 {
+			found = append(found, identicalKey{entry: entry, key: key, languages: same})
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No values identical to the base language found.\n\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Values identical to the base language (%s) in every language found: %d\n", baseLanguage, len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, f := range found {
+		fmt.Fprintf(output, "Key: \"%s\" (line %d in %s): \"%s\"\n", f.key, f.entry.LineNum, f.entry.File, f.entry.Value)
+		fmt.Fprintf(output, "  Same in: %s\n", strings.Join(f.languages, ", "))
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// languageForPath returns the language of a file from the nearest
+// enclosing xx.lproj directory, or "unknown"
+func languageForPath(path string) string {
+	for dir := filepath.Dir(path); dir != "." && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if base := filepath.Base(dir); strings.HasSuffix(base, ".lproj") {
+			return strings.TrimSuffix(base, ".lproj")
+		}
+	}
+	return "unknown"
+}
+
+// countEmptyValues counts entries whose value is blank. kvPattern doesn't
+// match "key" = ""; at all, so those are found among the malformed lines.
+func countEmptyValues(result *analyzer.Result, separator, quote string) int {
+	count := 0
+	for _, entry := range result.Entries {
+		if strings.TrimSpace(entry.Value) == "" {
+			count++
+		}
+	}
+
+	for _, lineNum := range result.MalformedLines {
+		if lineNum <= len(result.RawLines) && skippedLineIssue(result.RawLines[lineNum-1], separator, quote).Code == "empty-value" {
+			count++
+		}
+	}
+	return count
+}
+
+// printLanguageTable prints an aligned table with one row per language,
+// sorted by language code, and a totals row. Translation progress is the
+// share of the base language's keys that each language defines.
+func printLanguageTable(output io.Writer, languages map[string]*languageStats, baseLanguage string) {
+	var names []string
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	base := languages[baseLanguage]
+	var total languageStats
+
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(writer, "Language\tFiles\tEntries\tUnique\tDuplicates\tEmpty\tTranslated\t\n")
+	for _, name := range names {
+		stats := languages[name]
+		translated := "-"
+		if base != nil && len(base.keys) > 0 {
+			shared := 0
+			for key := range base.keys {
+				if stats.keys[key] {
+					shared++
+				}
+			}
+			translated = fmt.Sprintf("%.1f%%", float64(shared)*100/float64(len(base.keys)))
+		}
+
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\t%d\t%s\t\n",
+			name, stats.Files, stats.Entries, stats.UniqueKeys, stats.Duplicates, stats.EmptyValues, translated)
+
+		total.Files += stats.Files
+		total.Entries += stats.Entries
+		total.UniqueKeys += stats.UniqueKeys
+		total.Duplicates += stats.Duplicates
+		total.EmptyValues += stats.EmptyValues
+	}
+	fmt.Fprintf(writer, "Total\t%d\t%d\t%d\t%d\t%d\t-\t\n",
+		total.Files, total.Entries, total.UniqueKeys, total.Duplicates, total.EmptyValues)
+	writer.Flush()
+	fmt.Fprintf(output, "\n")
+}
+
+// findStringsFiles returns every .strings file under root, sorted by path,
+// together with the number of files skipped because they matched an exclude pattern
+func findStringsFiles(root string, excludePatterns []string) ([]string, int, error) {
+	var files []string
+	skipped := 0
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".strings" {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		for _, pattern := range excludePatterns {
+			if matchExcludePattern(pattern, filepath.ToSlash(relPath)) {
+				skipped++
+				return nil
+			}
+		}
+
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Strings(files)
+	return files, skipped, nil
+}
+
+// readArchiveStrings reads every .strings file from a .zip, .tar, .tar.gz or
+// .tgz archive into memory, keyed by its path inside the archive, together
+// with the number of files skipped because they matched an exclude pattern
+func readArchiveStrings(filename string, excludePatterns []string) (map[string][]byte, int, error) {
+	files := make(map[string][]byte)
+	skipped := 0
+
+	include := func(name string) bool {
+		if path.Ext(name) != ".strings" {
+			return false
+		}
+		for _, pattern := range excludePatterns {
+			if matchExcludePattern(pattern, strings.TrimPrefix(name, "./")) {
+				skipped++
+				return false
+			}
+		}
+		return true
+	}
+
+	lower := strings.ToLower(filename)
+	if strings.HasSuffix(lower, ".zip") {
+		reader, err := zip.OpenReader(filename)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer reader.Close()
+
+		for _, entry := range reader.File {
+			if entry.FileInfo().IsDir() || !include(entry.Name) {
+				continue
+			}
+			rc, err := entry.Open()
+			if err != nil {
+				return nil, 0, fmt.Errorf("%s: %w", entry.Name, err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, 0, fmt.Errorf("%s: %w", entry.Name, err)
+			}
+			files[entry.Name] = data
+		}
+		return files, skipped, nil
+	}
+
+	if !strings.HasSuffix(lower, ".tar") && !strings.HasSuffix(lower, ".tar.gz") && !strings.HasSuffix(lower, ".tgz") {
+		return nil, 0, fmt.Errorf("unsupported archive %s: expected .zip, .tar, .tar.gz or .tgz", filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var input io.Reader = file
+	if !strings.HasSuffix(lower, ".tar") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer gz.Close()
+		input = gz
+	}
+
+	tr := tar.NewReader(input)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if header.Typeflag != tar.TypeReg || !include(header.Name) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", header.Name, err)
+		}
+		files[header.Name] = data
+	}
+	return files, skipped, nil
+}
+
+// matchExcludePattern matches a slash-separated relative path against a
+// gitignore-style glob. A pattern without a slash matches any single path
+// component, "**" matches any number of components, and a pattern matching a
+// directory also matches everything inside it.
+func matchExcludePattern(pattern, relPath string) bool {
+	pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+	parts := strings.Split(relPath, "/")
+
+	if !strings.Contains(pattern, "/") {
+		for _, part := range parts {
+			if matched, _ := filepath.Match(pattern, part); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	for i := 1; i <= len(parts); i++ {
+		if matchGlobParts(patternParts, parts[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlobParts(patternParts, parts []string) bool {
+	if len(patternParts) == 0 {
+		return len(parts) == 0
+	}
+
+	if patternParts[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchGlobParts(patternParts[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+	if matched, _ := filepath.Match(patternParts[0], parts[0]); !matched {
+		return false
+	}
+	return matchGlobParts(patternParts[1:], parts[1:])
+}
+
+// reportLiteralNewlines lists entries whose value contains a raw line break,
+// i.e. "key" = "first line followed by more lines that end in ";. Xcode
+// accepts these but most tooling expects \n escapes on a single line.
+func reportLiteralNewlines(output io.Writer, rawLines []string, separator string, quiet bool) {
+	if separator == "" {
+		separator = "="
+	}
+	openPattern := regexp.MustCompile(`^\s*"([^"]+)"\s*` + regexp.QuoteMeta(separator) + `\s*"([^"]*)$`)
+	closePattern := regexp.MustCompile(`^[^"]*"\s*;`)
+
+	type multiLineEntry struct {
+		key       string
+		startLine int
+		endLine   int
+	}
+	var found []multiLineEntry
+
+	for i := 0; i < len(rawLines); i++ {
+		matches := openPattern.FindStringSubmatch(rawLines[i])
+		if matches == nil {
+			continue
+		}
+
+		// Look for the line that closes the value
+		for j := i + 1; j < len(rawLines); j++ {
+			if closePattern.MatchString(rawLines[j]) {
+				found = append(found, multiLineEntry{key: matches[1], startLine: i + 1, endLine: j + 1})
+				i = j
+				break
+			}
+			if openPattern.MatchString(rawLines[j]) {
+				break
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No values with literal line breaks found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Values with literal line breaks found: %d\n", len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, entry := range found {
+		fmt.Fprintf(output, "Key: \"%s\" starts at line %d and spans %d lines (use \\n instead)\n",
+			entry.key, entry.startLine, entry.endLine-entry.startLine+1)
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// findKeyCharIssues returns the problems with an entry's key. A key can't
+// hold a quote itself, but an unescaped quote in the original key leaves
+// quoted text before the part that was matched, so the raw line is checked
+// for that. Equals signs, semicolons and the separator are almost always
+// a sign that the key and value were split in the wrong place.
+func findKeyCharIssues(entry analyzer.KeyValue, rawLine, separator string) []string {
+	var issues []string
+
+	if rawLine != "" {
+		if idx := strings.Index(rawLine, `"`+entry.Key+`"`); idx > 0 && strings.Contains(rawLine[:idx], `"`) {
+			issues = append(issues, "unescaped quote before the key; the key may have been cut short")
+		}
+	}
+
+	for _, char := range []string{"=", ";", separator} {
+		if char != "" && strings.Contains(entry.Key, char) && !containsString(issues, "contains '"+char+"'") {
+			issues = append(issues, "contains '"+char+"'")
+		}
+	}
+	return issues
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// reportKeyCharIssues lists keys that contain characters a key shouldn't,
+// together with the raw line they were read from
+func reportKeyCharIssues(output io.Writer, entries []analyzer.KeyValue, rawLines []string, separator string, quiet bool) {
+	var found []analyzer.KeyValue
+	issuesByEntry := make(map[int][]string)
+
+	for i, entry := range entries {
+		// Raw lines are only kept for the input file itself
+		rawLine := ""
+		if entry.File == "" && entry.LineNum > 0 && entry.LineNum <= len(rawLines) {
+			rawLine = rawLines[entry.LineNum-1]
+		}
+		if issues := findKeyCharIssues(entry, rawLine, separator); len(issues) > 0 {
+			found = append(found, entry)
+			issuesByEntry[i] = issues
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No suspicious keys found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Suspicious keys found: %d\n", len(found))
+	fmt.Fprintf(output, "====================\n")
+	for i, entry := range entries {
+		issues, ok := issuesByEntry[i]
+		if !ok {
+			continue
+		}
+		if entry.File != "" {
+			fmt.Fprintf(output, "Key: \"%s\" (line %d in %s)\n", entry.Key, entry.LineNum, entry.File)
+		} else {
+			fmt.Fprintf(output, "Key: \"%s\" (line %d)\n", entry.Key, entry.LineNum)
+			fmt.Fprintf(output, "  Line: %s\n", strings.TrimSpace(rawLines[entry.LineNum-1]))
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(output, "  - %s\n", issue)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+}
+
+// reportEmptyKeys lists entries of the form "" = "value";. The behaviour of
+// an empty key is undefined on iOS, so these are reported as errors.
+func reportEmptyKeys(output io.Writer, inputFile string, emptyKeys []analyzer.KeyValue, quiet bool) {
+	if quiet {
+		for _, entry := range emptyKeys {
+			fmt.Fprintf(output, "%s: line %d: empty key\n", inputFile, entry.LineNum)
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Empty keys found: %d\n", len(emptyKeys))
+	fmt.Fprintf(output, "====================\n")
+	for _, entry := range emptyKeys {
+		fmt.Fprintf(output, "  %s: \"%s\"\n", lineLabel(entry), entry.Value)
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// readWordList reads a word list such as a spellcheck dictionary, in lower
+// case so that lookups are case-insensitive
+func readWordList(filename string) (map[string]bool, error) {
+	listed, err := readKeyList(filename)
+	if err != nil {
+		return nil, err
+	}
+	words := make(map[string]bool, len(listed))
+	for word := range listed {
+		words[strings.ToLower(word)] = true
+	}
+	return words, nil
+}
+
+// valueWords splits a value into the words a spell check should look at.
+// Placeholders and backslash escapes are skipped, and so are capitalized
+// words in the middle of a sentence, which are taken to be proper nouns.
+// Words are returned in lower case.
+func valueWords(value string) []string {
+	// Blank out placeholders so their letters aren't read as words
+	runes := []rune(value)
+	for _, token := range analyzer.ExtractTokens(value) {
+		start := utf8.RuneCountInString(value[:token.Start])
+		end := start + utf8.RuneCountInString(token.Text)
+		for i := start; i < end; i++ {
+			runes[i] = ' '
+		}
+	}
+	// An escaped apostrophe belongs to the word, as in can\'t
+	runes = []rune(strings.ReplaceAll(string(runes), "\\'", "'"))
+
+	var words []string
+	sentenceStart := true
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			// Skip the escaped character as well, e.g. the n of \n
+			i += 2
+			continue
+		case r == '.' || r == '!' || r == '?' || r == ':':
+			sentenceStart = true
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) ||
+				((runes[i] == '\'' || runes[i] == '’') && i+1 < len(runes) && unicode.IsLetter(runes[i+1]))) {
+				i++
+			}
+			word := string(runes[start:i])
+			if !unicode.IsUpper(runes[start]) || sentenceStart && !isUpperWord(word) {
+				words = append(words, strings.ToLower(word))
+			}
+			sentenceStart = false
+			continue
+		case unicode.IsDigit(r):
+			sentenceStart = false
+		}
+		i++
+	}
+	return words
+}
+
+// isUpperWord reports whether a word has more than one letter and no lower
+// case letters, like an acronym
+func isUpperWord(word string) bool {
+	return utf8.RuneCountInString(word) > 1 && strings.ToUpper(word) == word
+}
+
+// reportUnknownWords lists values containing words that aren't in the
+// dictionary, with the unknown words of each value in order of appearance
+func reportUnknownWords(output io.Writer, result *analyzer.Result, dictionary map[string]bool, quiet bool) {
+	type finding struct {
+		key   string
+		words []string
+	}
+	var findings []finding
+
+	for _, key := range result.KeyOrder {
+		seen := make(map[string]bool)
+		var unknown []string
+		for _, word := range valueWords(result.UniqueEntries[key].Value) {
+			if !dictionary[word] && !seen[word] {
+				seen[word] = true
+				unknown = append(unknown, word)
+			}
+		}
+		if len(unknown) > 0 {
+			findings = append(findings, finding{key: key, words: unknown})
+		}
+	}
+
+	if len(findings) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No unknown words found.\n\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Values with unknown words found: %d\n", len(findings))
+	fmt.Fprintf(output, "====================\n")
+	for _, f := range findings {
+		fmt.Fprintf(output, "Key: \"%s\" (line %d): %s\n", f.key, result.UniqueEntries[f.key].LineNum, strings.Join(f.words, ", "))
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// reportMixedLineEndings describes a file that uses both \r\n and \n line
+// endings, which usually means it was edited on different platforms
+func reportMixedLineEndings(output io.Writer, inputFile string, stats analyzer.LineEndingStats, quiet bool) {
+	if quiet {
+		fmt.Fprintf(output, "%s:%d: mixed line endings (%d CRLF, %d LF)\n", inputFile, stats.FirstChange, stats.CRLF, stats.LF)
+		return
+	}
+
+	fmt.Fprintf(output, "Mixed line endings found: %d CRLF, %d LF\n", stats.CRLF, stats.LF)
+	fmt.Fprintf(output, "====================\n")
+	fmt.Fprintf(output, "  Line endings first change at line %d\n", stats.FirstChange)
+	fmt.Fprintf(output, "  Use -clean with -line-endings lf or crlf to write a normalized copy\n")
+	fmt.Fprintf(output, "\n")
+}
+
+// Capitalization styles recognized by capitalizationStyle
+const (
+	styleTitle    = "Title Case"
+	styleSentence = "Sentence case"
+	styleLower    = "lower case"
+	styleUpper    = "UPPER CASE"
+)
+
+// minorWords stay lower case in Title Case ("Save as Draft")
+var minorWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "by": true, "for": true,
+	"in": true, "of": true, "on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// capitalizationStyle guesses the capitalization style of a value from the
+// words that start with a letter. Values with fewer than two such words
+// ("OK", "%@") can't be told apart and give "".
+func capitalizationStyle(value string) string {
+	var words []string
+	for _, field := range strings.Fields(value) {
+		field = strings.TrimLeftFunc(field, func(r rune) bool { return unicode.IsPunct(r) })
+		if r, _ := utf8.DecodeRuneInString(field); unicode.IsLetter(r) {
+			words = append(words, field)
+		}
+	}
+	if len(words) < 2 {
+		return ""
+	}
+
+	switch {
+	case strings.ToUpper(value) == value:
+		return styleUpper
+	case strings.ToLower(value) == value:
+		return styleLower
+	}
+
+	first, _ := utf8.DecodeRuneInString(words[0])
+	if !unicode.IsUpper(first) {
+		return ""
+	}
+	for _, word := range words[1:] {
+		r, _ := utf8.DecodeRuneInString(word)
+		if !unicode.IsUpper(r) && !minorWords[strings.ToLower(word)] {
+			return styleSentence
+		}
+	}
+	return styleTitle
+}
+
+// reportCapitalization prints how many values use each capitalization style
+// and flags values whose style differs from the most common one among keys
+// that share a prefix (everything before the last separator in the key)
+func reportCapitalization(output io.Writer, result *analyzer.Result, separator string, quiet bool) {
+	styles := make(map[string]string)
+	counts := make(map[string]int)
+	groups := make(map[string][]string)
+	var prefixes []string
+
+	for _, key := range result.KeyOrder {
+		style := capitalizationStyle(result.UniqueEntries[key].Value)
+		if style == "" {
+			continue
+		}
+		styles[key] = style
+		counts[style]++
+
+		prefix := ""
+		if separator != "" {
+			if idx := strings.LastIndex(key, separator); idx > 0 {
+				prefix = key[:idx]
+			}
+		}
+		if _, exists := groups[prefix]; !exists {
+			prefixes = append(prefixes, prefix)
+		}
+		groups[prefix] = append(groups[prefix], key)
+	}
+
+	if !quiet {
+		fmt.Fprintf(output, "Capitalization styles:\n")
+		fmt.Fprintf(output, "====================\n")
+		for _, style := range []string{styleTitle, styleSentence, styleLower, styleUpper} {
+			fmt.Fprintf(output, "  %s: %d\n", style, counts[style])
+		}
+		fmt.Fprintf(output, "\n")
+	}
+
+	type outlier struct {
+		key      string
+		style    string
+		prefix   string
+		majority string
+	}
+	var outliers []outlier
+
+	for _, prefix := range prefixes {
+		keys := groups[prefix]
+		groupCounts := make(map[string]int)
+		for _, key := range keys {
+			groupCounts[styles[key]]++
+		}
+
+		// The majority style must be a clear winner for outliers to mean anything
+		majority, majorityCount, tied := "", 0, false
+		for style, count := range groupCounts {
+			if count > majorityCount {
+				majority, majorityCount, tied = style, count, false
+			} else if count == majorityCount {
+				tied = true
+			}
+		}
+		if tied || majorityCount == len(keys) {
+			continue
+		}
+
+		for _, key := range keys {
+			if styles[key] != majority {
+				outliers = append(outliers, outlier{key: key, style: styles[key], prefix: prefix, majority: majority})
+			}
+		}
+	}
+
+	if len(outliers) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No capitalization outliers found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Capitalization outliers found: %d\n", len(outliers))
+	fmt.Fprintf(output, "====================\n")
+	for _, o := range outliers {
+		entry := result.UniqueEntries[o.key]
+		group := "keys without a prefix"
+		if o.prefix != "" {
+			group = fmt.Sprintf("\"%s\" keys", o.prefix)
+		}
+		fmt.Fprintf(output, "Key: \"%s\" (line %d) is %s, but most %s are %s\n", o.key, entry.LineNum, o.style, group, o.majority)
+		fmt.Fprintf(output, "  Value: \"%s\"\n", entry.Value)
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// skippedLineIssue gives a best guess at why a non-comment line didn't
+// parse as a key-value pair
+func skippedLineIssue(line, separator, quote string) analyzer.Issue {
+	issues := analyzer.ValidateEntryWithOptions(line, analyzer.ParseOptions{Separator: separator, Quote: quote})
+	if len(issues) == 0 {
+		return analyzer.Issue{Code: "malformed", Message: "doesn't match \"key\" = \"value\";"}
+	}
+	return issues[0]
+}
+
+// reportSkippedLines lists every line that is neither blank, a comment nor
+// a key-value pair, with a best guess at what is wrong with it
+func reportSkippedLines(output io.Writer, inputFile string, result *analyzer.Result, separator, quote string, quiet bool) {
+	if separator == "" {
+		separator = "="
+	}
+
+	if quiet {
+		for _, lineNum := range result.MalformedLines {
+			fmt.Fprintf(output, "%s: line %d: %s\n", inputFile, lineNum, skippedLineIssue(result.RawLines[lineNum-1], separator, quote).Message)
+		}
+		return
+	}
+
+	if len(result.MalformedLines) == 0 {
+		fmt.Fprintf(output, "No skipped lines found.\n")
+		return
+	}
+
+	fmt.Fprintf(output, "Skipped lines found: %d\n", len(result.MalformedLines))
+	fmt.Fprintf(output, "====================\n")
+	for _, lineNum := range result.MalformedLines {
+		line := result.RawLines[lineNum-1]
+		fmt.Fprintf(output, "  Line %d: %s\n", lineNum, skippedLineIssue(line, separator, quote).Message)
+		fmt.Fprintf(output, "    %s\n", strings.TrimSpace(line))
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// keyEdgeSeparators are stripped from both ends of a key by
+// reportTrimmedKeyCollisions
+const keyEdgeSeparators = "_-.: "
+
+// reportTrimmedKeyCollisions groups keys that become the same once leading
+// and trailing separators are stripped, such as "_home", "home." and "home",
+// which usually means one of them is a typo
+func reportTrimmedKeyCollisions(output io.Writer, result *analyzer.Result, quiet bool) {
+	groups := make(map[string][]string)
+	var normalizedKeys []string
+	for _, key := range result.KeyOrder {
+		normalized := strings.Trim(key, keyEdgeSeparators)
+		if normalized == "" {
+			continue
+		}
+		if _, exists := groups[normalized]; !exists {
+			normalizedKeys = append(normalizedKeys, normalized)
+		}
+		groups[normalized] = append(groups[normalized], key)
+	}
+
+	var found []string
+	for _, normalized := range normalizedKeys {
+		if len(groups[normalized]) > 1 {
+			found = append(found, normalized)
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No keys differing only by leading or trailing separators found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Keys differing only by leading or trailing separators found: %d\n", len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, normalized := range found {
+		fmt.Fprintf(output, "Normalized key: \"%s\"\n", normalized)
+		for _, key := range groups[normalized] {
+			fmt.Fprintf(output, "    Line %d: \"%s\"\n", result.UniqueEntries[key].LineNum, key)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+}
+
+// placeholderOnly reports whether a value has at least one placeholder and
+// nothing else but whitespace. An escaped %% counts as literal text.
+func placeholderOnly(value string) bool {
+	tokens := analyzer.ExtractTokens(value)
+	if len(tokens) == 0 {
+		return false
+	}
+
+	rest := value
+	for i := len(tokens) - 1; i >= 0; i-- {
+		rest = rest[:tokens[i].Start] + rest[tokens[i].End:]
+	}
+	return strings.TrimSpace(rest) == ""
+}
+
+// reportPlaceholderOnlyValues lists entries whose value is nothing but
+// placeholders, which usually means a translator deleted the literal words
+func reportPlaceholderOnlyValues(output io.Writer, entries []analyzer.KeyValue, quiet bool) {
+	var found []analyzer.KeyValue
+	for _, entry := range entries {
+		if placeholderOnly(entry.Value) {
+			found = append(found, entry)
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No placeholder-only values found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Placeholder-only values found: %d\n", len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, entry := range found {
+		fmt.Fprintf(output, "Key: \"%s\" (line %d): \"%s\"\n", entry.Key, entry.LineNum, entry.Value)
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// validEscapes lists the characters that may follow a backslash in a
+// .strings value, as understood by the property list parser iOS loads the
+// file with: \a \b \f \n \r \t \v, an escaped quote or backslash, an
+// octal escape starting with a digit 0-7, and \U (or \u) followed by four
+// hex digits. Any other escape is rendered literally or dropped.
+const validEscapes = `abfnrtv"'\01234567Uu`
+
+// invalidEscape is an escape sequence in a value that iOS doesn't interpret
+type invalidEscape struct {
+	Sequence string
+	Position int // 1-based character position of the backslash in the value
+}
+
+// invalidEscapes returns the escape sequences of value that aren't in
+// validEscapes, and \U escapes without four hex digits
+func invalidEscapes(value string) []invalidEscape {
+	var found []invalidEscape
+	runes := []rune(value)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\\' {
+			continue
+		}
+		if i+1 == len(runes) {
+			found = append(found, invalidEscape{Sequence: "\\", Position: i + 1})
+			break
+		}
+
+		next := runes[i+1]
+		if !strings.ContainsRune(validEscapes, next) {
+			found = append(found, invalidEscape{Sequence: string(runes[i : i+2]), Position: i + 1})
+		} else if next == 'U' || next == 'u' {
+			digits := 0
+			for digits < 4 && i+2+digits < len(runes) && isHexDigit(runes[i+2+digits]) {
+				digits++
+			}
+			if digits < 4 {
+				found = append(found, invalidEscape{Sequence: string(runes[i : i+2+digits]), Position: i + 1})
+			}
+		}
+		// Skip the escaped character, so \\q is a backslash followed by q
+		i++
+	}
+	return found
+}
+
+func isHexDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+// reportInvalidEscapes lists values with escape sequences iOS doesn't
+// interpret, with the position of each one in the value
+func reportInvalidEscapes(output io.Writer, entries []analyzer.KeyValue, quiet bool) {
+	type escapeIssue struct {
+		entry   analyzer.KeyValue
+		escapes []invalidEscape
+	}
+	var found []escapeIssue
+	for _, entry := range entries {
+		if escapes := invalidEscapes(entry.Value); len(escapes) > 0 {
+			found = append(found, escapeIssue{entry, escapes})
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No invalid escape sequences found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Values with invalid escape sequences found: %d\n", len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, issue := range found {
+		fmt.Fprintf(output, "Key: \"%s\" (line %d): \"%s\"\n", issue.entry.Key, issue.entry.LineNum, issue.entry.Value)
+		for _, escape := range issue.escapes {
+			fmt.Fprintf(output, "  %s at position %d\n", escape.Sequence, escape.Position)
+		}
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// keyWhitespacePattern matches a run of whitespace inside a key
+var keyWhitespacePattern = regexp.MustCompile(`\s+`)
+
+// reportWhitespaceKeyCollisions groups keys that become the same once every
+// run of whitespace is replaced by a single "_", such as "home title" and
+// "home_title", which usually means a space was typed by accident
+func reportWhitespaceKeyCollisions(output io.Writer, result *analyzer.Result, quiet bool) {
+	groups := make(map[string][]string)
+	var normalizedKeys []string
+	for _, key := range result.KeyOrder {
+		normalized := keyWhitespacePattern.ReplaceAllString(key, "_")
+		if _, exists := groups[normalized]; !exists {
+			normalizedKeys = append(normalizedKeys, normalized)
+		}
+		groups[normalized] = append(groups[normalized], key)
+	}
+
+	var found []string
+	for _, normalized := range normalizedKeys {
+		if len(groups[normalized]) > 1 {
+			found = append(found, normalized)
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No keys differing only by whitespace found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Keys differing only by whitespace found: %d\n", len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, normalized := range found {
+		fmt.Fprintf(output, "Normalized key: \"%s\"\n", normalized)
+		for _, key := range groups[normalized] {
+			fmt.Fprintf(output, "    Line %d: \"%s\"\n", result.UniqueEntries[key].LineNum, key)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+}
+
+// reportNamespaceDuplicates reports keys without a namespace that also
+// exist with one, such as "ok" and "feature_a.ok". Keys that only differ in
+// their namespace ("feature_a.ok" and "feature_b.ok") are not reported.
+// The prefix pattern is matched at the start of each key.
+func reportNamespaceDuplicates(output io.Writer, result *analyzer.Result, prefix *regexp.Regexp, quiet bool) {
+	namespaced := make(map[string][]string)
+	for _, key := range result.KeyOrder {
+		loc := prefix.FindStringIndex(key)
+		if loc == nil || loc[0] != 0 || loc[1] == 0 || loc[1] == len(key) {
+			continue
+		}
+		name := key[loc[1]:]
+		namespaced[name] = append(namespaced[name], key)
+	}
+
+	var found []string
+	for _, key := range result.KeyOrder {
+		if len(namespaced[key]) > 0 {
+			found = append(found, key)
+		}
+	}
+
+	if len(found) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No logical duplicates found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Logical duplicates found: %d\n", len(found))
+	fmt.Fprintf(output, "====================\n")
+	for _, key := range found {
+		entry := result.UniqueEntries[key]
+		fmt.Fprintf(output, "Key: \"%s\" (line %d) also exists with a namespace:\n", key, entry.LineNum)
+		fmt.Fprintf(output, "    Line %d: \"%s\" = \"%s\"\n", entry.LineNum, key, entry.Value)
+		for _, other := range namespaced[key] {
+			otherEntry := result.UniqueEntries[other]
+			fmt.Fprintf(output, "    Line %d: \"%s\" = \"%s\"\n", otherEntry.LineNum, other, otherEntry.Value)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+}
+
+// runLintCommand runs command once per unique entry, passing the key and
+// value as two extra arguments, with at most jobs commands running at once.
+// A non-zero exit status is a violation and the command's stderr is its
+// message. It returns the number of violations.
+func runLintCommand(output io.Writer, command string, jobs int, result *analyzer.Result, quiet bool) (int, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return 0, fmt.Errorf("empty -lint-cmd")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return 0, fmt.Errorf("lint command: %w", err)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// Messages are stored by position so the report keeps file order
+	messages := make([]string, len(result.KeyOrder))
+	failed := make([]bool, len(result.KeyOrder))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, jobs)
+	for i, key := range result.KeyOrder {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, key string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			cmd := exec.Command(args[0], append(args[1:], key, result.UniqueEntries[key].Value)...)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				failed[i] = true
+				messages[i] = strings.TrimSpace(stderr.String())
+				if messages[i] == "" {
+					messages[i] = err.Error()
+				}
+			}
+		}(i, key)
+	}
+	wg.Wait()
+
+	violations := 0
+	for _, f := range failed {
+		if f {
+			violations++
+		}
+	}
+
+	if violations == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No lint violations found.\n")
+		}
+		return 0, nil
+	}
+
+	fmt.Fprintf(output, "Lint violations found: %d\n", violations)
+	fmt.Fprintf(output, "====================\n")
+	for i, key := range result.KeyOrder {
+		if !failed[i] {
+			continue
+		}
+		entry := result.UniqueEntries[key]
+		fmt.Fprintf(output, "Key: \"%s\" (line %d)\n", key, entry.LineNum)
+		fmt.Fprintf(output, "  Value: \"%s\"\n", entry.Value)
+		for _, line := range strings.Split(messages[i], "\n") {
+			fmt.Fprintf(output, "  - %s\n", line)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+	return violations, nil
+}
+
+// reportOrphanedComments lists comment blocks that no key-value line
+// follows, with the first line of each block
+func reportOrphanedComments(output io.Writer, inputFile string, result *analyzer.Result, quiet bool) {
+	blocks := result.OrphanedComments
+	if quiet {
+		for _, block := range blocks {
+			fmt.Fprintf(output, "%s: line %d: orphaned comment (%s)\n", inputFile, block.Start, strings.ToLower(lineRangeLabel(block)))
+		}
+		return
+	}
+
+	if len(blocks) == 0 {
+		fmt.Fprintf(output, "No orphaned comments found.\n\n")
+		return
+	}
+
+	fmt.Fprintf(output, "Orphaned comments found: %d\n", len(blocks))
+	fmt.Fprintf(output, "====================\n")
+	for _, block := range blocks {
+		fmt.Fprintf(output, "  %s: %s\n", lineRangeLabel(block), strings.TrimSpace(result.RawLines[block.Start-1]))
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// lineRangeLabel describes a line range as "Line n" or "Lines n-m"
+func lineRangeLabel(r analyzer.LineRange) string {
+	if r.Start == r.End {
+		return fmt.Sprintf("Line %d", r.Start)
+	}
+	return fmt.Sprintf("Lines %d-%d", r.Start, r.End)
+}
+
+// reportTrailingContent lists key-value lines with something other than
+// whitespace or a comment after the terminating semicolon, such as
+// "k" = "v";; which a lenient parse silently accepts
+func reportTrailingContent(output io.Writer, inputFile string, lines []int, rawLines []string, quiet bool) {
+	if quiet {
+		for _, lineNum := range lines {
+			fmt.Fprintf(output, "%s: line %d: unexpected content after ';'\n", inputFile, lineNum)
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Lines with content after ';' found: %d\n", len(lines))
+	fmt.Fprintf(output, "====================\n")
+	for _, lineNum := range lines {
+		fmt.Fprintf(output, "  Line %d: %s\n", lineNum, strings.TrimSpace(rawLines[lineNum-1]))
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// reportKeyReferences validates %{key} tokens: every referenced key must
+// exist and references must not form a cycle (A -> B -> A)
+func reportKeyReferences(output io.Writer, result *analyzer.Result, quiet bool) {
+	uniqueEntries := result.UniqueEntries
+	references := make(map[string][]string)
+	keys := result.SortedKeys()
+	for _, key := range keys {
+		for _, token := range analyzer.ExtractTokens(uniqueEntries[key].Value) {
+			if token.Kind == analyzer.TokenNamed {
+				references[key] = append(references[key], token.Name)
+			}
+		}
+	}
+
+	var unknown []string
+	for _, key := range keys {
+		for _, ref := range references[key] {
+			if _, exists := uniqueEntries[ref]; !exists {
+				unknown = append(unknown, fmt.Sprintf("Key: \"%s\" (line %d) references unknown key %%{%s}",
+					key, uniqueEntries[key].LineNum, ref))
+			}
+		}
+	}
+
+	// Depth-first search; reaching a key that is still on the path closes a cycle
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+	var cycles []string
+
+	var visit func(key string)
+	visit = func(key string) {
+		state[key] = inProgress
+		path = append(path, key)
+
+		for _, ref := range references[key] {
+			if _, exists := uniqueEntries[ref]; !exists {
+				continue
+			}
+			switch state[ref] {
+			case unvisited:
+				visit(ref)
+			case inProgress:
+				start := 0
+				for path[start] != ref {
+					start++
+				}
+				chain := append(append([]string(nil), path[start:]...), ref)
+				cycles = append(cycles, "\""+strings.Join(chain, "\" -> \"")+"\"")
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[key] = done
+	}
+
+	for _, key := range keys {
+		if state[key] == unvisited {
+			visit(key)
+		}
+	}
+
+	if len(unknown) == 0 && len(cycles) == 0 {
+		if !quiet {
+			fmt.Fprintf(output, "No key reference problems found.\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(output, "Key reference problems found: %d\n", len(unknown)+len(cycles))
+	fmt.Fprintf(output, "====================\n")
+	for _, problem := range unknown {
+		fmt.Fprintf(output, "%s\n", problem)
+	}
+	for _, cycle := range cycles {
+		fmt.Fprintf(output, "Reference cycle: %s\n", cycle)
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// writeGoSource writes a gofmt-formatted Go file declaring the unique
+// entries as a map. Keys and values are unescaped from .strings syntax and
+// re-quoted as Go string literals.
+func writeGoSource(filename, packageName, inputFile string, result *analyzer.Result) error {
+	if !token.IsIdentifier(packageName) {
+		return fmt.Errorf("invalid package name %q", packageName)
+	}
+
+	uniqueEntries := result.UniqueEntries
+	keys := result.SortedKeys()
+
+	var source bytes.Buffer
+	fmt.Fprintf(&source, "// Code generated by localization-analyzer from %s. DO NOT EDIT.\n\n", filepath.Base(inputFile))
+	fmt.Fprintf(&source, "package %s\n\n", packageName)
+	fmt.Fprintf(&source, "// Strings maps localization keys to their values\n")
+	fmt.Fprintf(&source, "var Strings = map[string]string{\n")
+	for _, key := range keys {
+		fmt.Fprintf(&source, "%s: %s,\n",
+			strconv.Quote(unescapeStringsValue(key)), strconv.Quote(unescapeStringsValue(uniqueEntries[key].Value)))
+	}
+	fmt.Fprintf(&source, "}\n")
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %w", err)
+	}
+
+	if err := os.WriteFile(filename, formatted, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// unescapeStringsValue resolves the backslash escapes supported in .strings
+// files (\n, \t, \r, \", \', \\, \0 and \Uxxxx) into the characters they stand for
+func unescapeStringsValue(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	var builder strings.Builder
+	runes := []rune(value)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\\' || i+1 == len(runes) {
+			builder.WriteRune(runes[i])
+			continue
+		}
+
+		i++
+		switch runes[i] {
+		case 'n':
+			builder.WriteRune('\n')
+		case 't':
+			builder.WriteRune('\t')
+		case 'r':
+			builder.WriteRune('\r')
+		case '0':
+			builder.WriteRune(0)
+		case 'U', 'u':
+			if i+4 < len(runes) {
+				if codePoint, err := strconv.ParseUint(string(runes[i+1:i+5]), 16, 32); err == nil {
+					builder.WriteRune(rune(codePoint))
+					i += 4
+					continue
+				}
+			}
+			builder.WriteRune('\\')
+			builder.WriteRune(runes[i])
+		default:
+			// \", \' and \\ stand for the character itself
+			builder.WriteRune(runes[i])
+		}
+	}
+
+	return builder.String()
+}
+
+// countValueLines returns the number of lines a value renders as, counting
+// \n escapes as line breaks
+func countValueLines(value string) int {
+	return strings.Count(value, `\n`) + 1
+}
+
+// printLineCountMismatches lists keys present in both files whose values
+// have a different number of lines, which often means content was lost
+func printLineCountMismatches(output io.Writer, base, other *analyzer.Result) {
+	var keys []string
+	for key, baseEntry := range base.UniqueEntries {
+		if otherEntry, exists := other.UniqueEntries[key]; exists &&
+			countValueLines(baseEntry.Value) != countValueLines(otherEntry.Value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(output, "Line count mismatches: %d\n", len(keys))
+	for _, key := range keys {
+		fmt.Fprintf(output, "  Key: \"%s\" has %d lines in the base file and %d lines in the translation\n",
+			key, countValueLines(base.UniqueEntries[key].Value), countValueLines(other.UniqueEntries[key].Value))
+	}
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+// analyzeLocalizationFile parses the named file, or standard input for "-"
+func analyzeLocalizationFile(filename string, opts analyzer.ParseOptions) (*analyzer.Result, error) {
+	if filename == "-" {
+		return analyzer.Parse(filename, os.Stdin, opts)
+	}
+	return analyzer.ParseFile(filename, opts)
+}
+
+// reportGettextStatus lists the entries of a .po file that are flagged
+// fuzzy, which gettext won't use until a translator confirms them, and the
+// entries without a translation
+func reportGettextStatus(output io.Writer, inputFile string, result *analyzer.Result, quiet bool) {
+	var fuzzy, untranslated []analyzer.KeyValue
+	for _, entry := range result.Entries {
+		if entry.Fuzzy {
+			fuzzy = append(fuzzy, entry)
+		}
+		if entry.Value == "" {
+			untranslated = append(untranslated, entry)
+		}
+	}
+
+	if quiet {
+		for _, entry := range fuzzy {
+			fmt.Fprintf(output, "%s:%d: fuzzy translation for \"%s\"\n", inputFile, entry.LineNum, entry.Key)
+		}
+		for _, entry := range untranslated {
+			fmt.Fprintf(output, "%s:%d: untranslated \"%s\"\n", inputFile, entry.LineNum, entry.Key)
+		}
+		return
+	}
+
+	if len(fuzzy) == 0 {
+		fmt.Fprintf(output, "No fuzzy entries found.\n\n")
+	} else {
+		fmt.Fprintf(output, "Fuzzy entries found: %d\n", len(fuzzy))
+		fmt.Fprintf(output, "====================\n")
+		for _, entry := range fuzzy {
+			fmt.Fprintf(output, "  Line %d: \"%s\" = \"%s\"\n", entry.LineNum, entry.Key, entry.Value)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+
+	if len(untranslated) == 0 {
+		fmt.Fprintf(output, "No untranslated entries found.\n\n")
+	} else {
+		fmt.Fprintf(output, "Untranslated entries found: %d\n", len(untranslated))
+		fmt.Fprintf(output, "====================\n")
+		for _, entry := range untranslated {
+			fmt.Fprintf(output, "  Line %d: \"%s\"\n", entry.LineNum, entry.Key)
+		}
+		fmt.Fprintf(output, "\n")
+	}
+}
+
+// metadataEntry is a "Name: value" pair read from a comment line by
+// extractMetadata
+type metadataEntry struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Line  int    `json:"line"`
+}
+
+// metadataLinePattern matches the "Name: value" or "Name=value" part of a
+// metadata comment. Names are a single word, so ordinary prose comments
+// aren't mistaken for metadata.
+var metadataLinePattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_.-]*)\s*[:=]\s*(.*?)\s*(?:\*/)?\s*$`)
+
+// extractMetadata reads the metadata of every comment line that starts with
+// prefix, in the order the names first appear. When a name appears more than
+// once the last value wins, so a trailing comment added by the latest
+// translator takes precedence. Lines that don't hold a "Name: value" pair or
+// that are inside a /* */ block are ignored.
+func extractMetadata(rawLines []string, prefix string) []metadataEntry {
+	var metadata []metadataEntry
+	index := make(map[string]int)
+	inBlockComment := false
+	for i, line := range rawLines {
+		trimmedLine := strings.TrimSpace(line)
+		if inBlockComment {
+			inBlockComment = !strings.Contains(trimmedLine, "*/")
+			continue
+		}
+		if strings.HasPrefix(trimmedLine, "/*") {
+			inBlockComment = !strings.Contains(trimmedLine[2:], "*/")
+		}
+		if !strings.HasPrefix(trimmedLine, prefix) {
+			continue
+		}
+
+		matches := metadataLinePattern.FindStringSubmatch(strings.TrimSpace(strings.TrimPrefix(trimmedLine, prefix)))
+		if matches == nil || matches[2] == "" {
+			continue
+		}
+		entry := metadataEntry{Name: matches[1], Value: matches[2], Line: i + 1}
+		if existing, seen := index[entry.Name]; seen {
+			metadata[existing] = entry
+			continue
+		}
+		index[entry.Name] = len(metadata)
+		metadata = append(metadata, entry)
+	}
+	return metadata
+}
+
+// printMetadata prints the metadata found by extractMetadata
+func printMetadata(output io.Writer, metadata []metadataEntry) {
+	if len(metadata) == 0 {
+		fmt.Fprintf(output, "No metadata comments found.\n")
+		return
+	}
+
+	fmt.Fprintf(output, "Metadata:\n")
+	for _, entry := range metadata {
+		fmt.Fprintf(output, "  %s: %s (line %d)\n", entry.Name, entry.Value, entry.Line)
+	}
+	fmt.Fprintf(output, "\n")
+}
+
+// hunkHeaderPattern matches the "@@ -a,b +c,d @@" header of a unified diff hunk
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// addedLinesSince returns the line numbers of filename that were added or
+// changed relative to the git ref, by running git diff with no context lines
+func addedLinesSince(filename, ref string) (map[int]bool, error) {
+	if filename == "-" {
+		return nil, fmt.Errorf("-since can't be used with stdin")
+	}
+
+	cmd := exec.Command("git", "diff", "--no-color", "--no-ext-diff", "-U0", ref, "--", filepath.Base(filename))
+	cmd.Dir = filepath.Dir(filename)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git diff against %s failed: %s", ref, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git diff against %s failed: %v", ref, err)
+	}
+
+	added := make(map[int]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		matches := hunkHeaderPattern.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+
+		start, _ := strconv.Atoi(matches[1])
+		count := 1
+		if matches[2] != "" {
+			count, _ = strconv.Atoi(matches[2])
+		}
+		for line := start; line < start+count; line++ {
+			added[line] = true
+		}
+	}
+	return added, scanner.Err()
+}
+
+// readFileAtRef returns the contents of filename as committed at the git
+// ref, without checking the ref out, by running git show
+func readFileAtRef(filename, ref string) ([]byte, error) {
+	dir := filepath.Dir(filename)
+	check := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	check.Dir = dir
+	if err := check.Run(); err != nil {
+		return nil, fmt.Errorf("-compare-branch needs %s to be inside a git repository", filename)
+	}
+
+	// "./name" makes git resolve the path relative to the file's directory
+	cmd := exec.Command("git", "show", ref+":./"+filepath.Base(filename))
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("reading %s at %s failed: %s", filename, ref, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("reading %s at %s failed: %v", filename, ref, err)
+	}
+	return out, nil
+}
+
+// filterNewDuplicates keeps the duplicate groups that have at least one
+// occurrence on an added line of the input file
+func filterNewDuplicates(duplicateKeys map[string][]analyzer.KeyValue, added map[int]bool) map[string][]analyzer.KeyValue {
+	filtered := make(map[string][]analyzer.KeyValue)
+	for key, entries := range duplicateKeys {
+		for _, entry := range entries {
+			if entry.File == "" && added[entry.LineNum] {
+				filtered[key] = entries
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// streamJSONLines writes one JSON object per key-value entry as soon as the
+// parser reports it, marking repeated occurrences with "duplicate": true.
+// Parsing is the same as for the other reports, including encoding
+// detection, /* */ comments and -follow-includes. Only the set of keys
+// seen so far is kept; entries and lines are not.
+func streamJSONLines(filename string, output io.Writer, opts analyzer.ParseOptions) error {
+	type jsonLine struct {
+		Key       string `json:"key"`
+		Value     string `json:"value"`
+		Line      int    `json:"line"`
+		Duplicate bool   `json:"duplicate"`
+	}
+
+	var input io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	// Each Encode call is a single write of one complete line
+	encoder := json.NewEncoder(output)
+	seenKeys := make(map[string]bool)
+	var writeErr error
+	writeEntry := func(entry analyzer.KeyValue) {
+		if writeErr != nil {
+			return
+		}
+		writeErr = encoder.Encode(jsonLine{Key: entry.Key, Value: entry.Value, Line: entry.LineNum, Duplicate: seenKeys[entry.Key]})
+		seenKeys[entry.Key] = true
+	}
+
+	if err := analyzer.ParseStream(filename, input, opts, writeEntry, nil); err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to write entry: %w", writeErr)
+	}
+	return nil
 }
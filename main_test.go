@@ -0,0 +1,741 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/localization-analyzer/analyzer"
+)
+
+// TestMain runs main with the arguments after "--" when the test binary is
+// started by runAnalyzer, so tests can check the real output and exit status
+func TestMain(m *testing.M) {
+	if os.Getenv("LOCALIZATION_ANALYZER_RUN_MAIN") == "1" {
+		for i, arg := range os.Args {
+			if arg == "--" {
+				os.Args = append([]string{os.Args[0]}, os.Args[i+1:]...)
+				break
+			}
+		}
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runAnalyzer runs the analyzer with args in dir and returns its standard
+// output and exit status
+func runAnalyzer(t *testing.T, dir string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^$", "--"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "LOCALIZATION_ANALYZER_RUN_MAIN=1")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("running analyzer: %v", err)
+	}
+	return stdout.String(), 0
+}
+
+// writeTestFile writes content to name in a new temporary directory and
+// returns the directory
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const duplicatesFile = `"a" = "1";
+"a" = "2";
+"b" = "x";
+"b" = "x";
+`
+
+func TestSummaryLineExitStatus(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", duplicatesFile)
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, 0},
+		{[]string{"-fail-on-duplicates"}, 1},
+		{[]string{"-fail-on-conflicts"}, 1},
+		{[]string{"-max-duplicates", "1"}, 1},
+		{[]string{"-max-duplicates", "2"}, 0},
+	}
+	for _, test := range tests {
+		args := append([]string{"-summary-line"}, test.args...)
+		out, status := runAnalyzer(t, dir, args...)
+		if status != test.want {
+			t.Errorf("%v: exit status %d, want %d", args, status, test.want)
+		}
+		if want := "entries=4 unique=2 duplicates=2 conflicts=1\n"; out != want {
+			t.Errorf("%v: output %q, want %q", args, out, want)
+		}
+	}
+}
+
+func TestMaxDuplicatesOverBudget(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", duplicatesFile)
+
+	out, status := runAnalyzer(t, dir, "-max-duplicates", "1")
+	if status != 1 {
+		t.Errorf("exit status %d, want 1", status)
+	}
+	if !strings.Contains(out, "Duplicate budget exceeded: 2/1 budget used.") {
+		t.Errorf("report doesn't show the exceeded budget:\n%s", out)
+	}
+
+	// Machine-readable reports leave the budget line out
+	for _, format := range []string{"-json", "-summary-line"} {
+		out, status := runAnalyzer(t, dir, format, "-max-duplicates", "1")
+		if status != 1 {
+			t.Errorf("%s: exit status %d, want 1", format, status)
+		}
+		if strings.Contains(out, "budget") {
+			t.Errorf("%s: output contains the budget line:\n%s", format, out)
+		}
+	}
+}
+
+func TestSinceDuplicatesNotReportedAsIgnored(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := writeTestFile(t, "Localizable.strings", duplicatesFile)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "Localizable.strings"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "base"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	file, err := os.OpenFile(filepath.Join(dir, "Localizable.strings"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("\"c\" = \"1\";\n\"c\" = \"2\";\n")
+	file.Close()
+
+	out, _ := runAnalyzer(t, dir, "-since", "HEAD", "-v")
+	if strings.Contains(out, "Ignored") {
+		t.Errorf("duplicates from before -since reported as ignored:\n%s", out)
+	}
+	if !strings.Contains(out, "Skipped 2 duplicate keys that only involve lines from before HEAD.") {
+		t.Errorf("report doesn't count the older duplicates:\n%s", out)
+	}
+	if !strings.Contains(out, "Found 1 duplicate keys") {
+		t.Errorf("report doesn't show the new duplicate:\n%s", out)
+	}
+}
+
+func TestFailOnPlaceholderTypes(t *testing.T) {
+	dir := writeTestFile(t, "README", "")
+	for language, value := range map[string]string{"en": "%d files", "fr": "%@ fichiers"} {
+		lproj := filepath.Join(dir, language+".lproj")
+		if err := os.Mkdir(lproj, 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := "\"files\" = \"" + value + "\";\n"
+		if err := os.WriteFile(filepath.Join(lproj, "Localizable.strings"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, status := runAnalyzer(t, dir, "-dir", ".", "-check-placeholder-types")
+	if status != 0 {
+		t.Errorf("exit status %d without -fail-on-placeholder-types, want 0", status)
+	}
+	if !strings.Contains(out, "Placeholder type mismatches between languages found: 1") {
+		t.Errorf("mismatch not reported:\n%s", out)
+	}
+
+	if _, status := runAnalyzer(t, dir, "-dir", ".", "-check-placeholder-types", "-fail-on-placeholder-types"); status != 1 {
+		t.Errorf("exit status %d with -fail-on-placeholder-types, want 1", status)
+	}
+}
+
+func TestJSONReportWithTextChecks(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", `"a" = "<b>Bold";
+"b" = "(open";
+"c" = "A rather long value";
+"c" = "A rather long value";
+`)
+	if err := os.WriteFile(filepath.Join(dir, "words.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every check that only has a text report must stay out of the JSON
+	out, status := runAnalyzer(t, dir, "-json", "-markup", "-brackets", "-max-len", "5",
+		"-control-chars", "-max-repeat", "3", "-dup-histogram", "-check-newlines", "-check-key-chars",
+		"-check-case", "-spellcheck", "words.txt", "-check-orphaned-comments", "-check-trimmed-keys",
+		"-check-placeholder-only", "-check-escapes", "-check-key-whitespace", "-namespace-prefix", `^[^.]+\.`,
+		"-check-refs", "-stats", "-lint-cmd", "true", "-include-commented", "-metadata-prefix", "//")
+	var report map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Errorf("output is not a single JSON document: %v\n%s", err, out)
+	}
+	if status != 1 {
+		t.Errorf("exit status %d, want 1 for values over -max-len", status)
+	}
+}
+
+// validateSchema checks value, decoded from JSON, against the subset of
+// JSON Schema that jsonSchemaFor produces, and returns every violation
+func validateSchema(schema map[string]interface{}, value interface{}, path string) []string {
+	var problems []string
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{path + ": not an object"}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required %q", path, name))
+			}
+		}
+		for name, field := range object {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					problems = append(problems, fmt.Sprintf("%s: unexpected property %q", path, name))
+				}
+				continue
+			}
+			problems = append(problems, validateSchema(property, field, path+"."+name)...)
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{path + ": not an array"}
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, item := range array {
+			problems = append(problems, validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			problems = append(problems, path+": not a string")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, path+": not a boolean")
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+			problems = append(problems, path+": not an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			problems = append(problems, path+": not a number")
+		}
+	}
+	return problems
+}
+
+func TestJSONReportMatchesSchema(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", `// Translated-By: Ana
+// MARK: - Login
+"a" = "1";
+"" = "empty key";
+// MARK: - Settings
+"a" = "2";
+"b" = "x";
+"b" = "x";
+`)
+
+	out, status := runAnalyzer(t, dir, "-print-schema")
+	if status != 0 {
+		t.Fatalf("-print-schema exit status %d", status)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &schema); err != nil {
+		t.Fatalf("schema is not JSON: %v", err)
+	}
+
+	out, _ = runAnalyzer(t, dir, "-json", "-metadata-prefix", "//")
+	var report interface{}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, out)
+	}
+	for _, key := range []string{"emptyKeys", "metadata"} {
+		if _, ok := report.(map[string]interface{})[key]; !ok {
+			t.Errorf("sample report has no %s to validate", key)
+		}
+	}
+	for _, problem := range validateSchema(schema, report, "report") {
+		t.Error(problem)
+	}
+}
+
+func TestPrintSchemaOnlyAsFlag(t *testing.T) {
+	dir := t.TempDir()
+
+	// As the value of -f it names the input file, not the flag
+	out, status := runAnalyzer(t, dir, "-f", "-print-schema")
+	if status != 1 || strings.Contains(out, "$schema") {
+		t.Errorf("-f -print-schema printed the schema or didn't fail (status %d):\n%s", status, out)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$", "--", "-help")
+	cmd.Env = append(os.Environ(), "LOCALIZATION_ANALYZER_RUN_MAIN=1")
+	help, _ := cmd.CombinedOutput()
+	if !strings.Contains(string(help), "-json") || strings.Contains(string(help), "print-schema") {
+		t.Errorf("-help should list the flags except -print-schema:\n%s", help)
+	}
+}
+
+func TestJSONLinesParsesLikeReports(t *testing.T) {
+	// A key-value line inside a block comment, and a Latin-1 "é"
+	dir := writeTestFile(t, "Localizable.strings", "/*\n\"old\" = \"commented out\";\n*/\n\"caf\xe9\" = \"1\";\n\"a\" = \"2\";\n\"a\" = \"3\";\n")
+
+	out, status := runAnalyzer(t, dir, "-jsonl")
+	if status != 0 {
+		t.Fatalf("exit status %d", status)
+	}
+	want := `{"key":"café","value":"1","line":4,"duplicate":false}
+{"key":"a","value":"2","line":5,"duplicate":false}
+{"key":"a","value":"3","line":6,"duplicate":true}
+`
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestDuplicateOccurrencesInLineOrder(t *testing.T) {
+	// The included file's entries are merged at the #include line, between
+	// the input file's own occurrences
+	dir := writeTestFile(t, "Localizable.strings", `"b" = "1";
+"a" = "1";
+#include "Other.strings"
+"a" = "2";
+"b" = "2";
+"a" = "3";
+`)
+	if err := os.WriteFile(filepath.Join(dir, "Other.strings"), []byte("\"x\" = \"0\";\n\"a\" = \"included\";\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _ := runAnalyzer(t, dir, "-json", "-follow-includes")
+	var report struct {
+		DuplicateKeys []struct {
+			Key         string
+			Occurrences []struct {
+				Line  int
+				Value string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, out)
+	}
+
+	// Ascending line numbers, with the input file's own occurrences before
+	// those of the included file
+	want := map[string][]int{"a": {2, 4, 6, 2}, "b": {1, 5}}
+	for _, duplicate := range report.DuplicateKeys {
+		var lines []int
+		for _, occurrence := range duplicate.Occurrences {
+			lines = append(lines, occurrence.Line)
+		}
+		if !reflect.DeepEqual(lines, want[duplicate.Key]) {
+			t.Errorf("%s: occurrences on lines %v, want %v", duplicate.Key, lines, want[duplicate.Key])
+		}
+		delete(want, duplicate.Key)
+	}
+	if len(want) > 0 {
+		t.Errorf("duplicate keys missing from the report: %v", want)
+	}
+}
+
+// writeFixture writes a synthetic .strings file with n entries for the
+// benchmarks. It is deterministic and mixes in what real files contain:
+// MARK sections, comments, format placeholders and, as every 20th entry, a
+// duplicate of the key before it, every other one with a different value.
+func writeFixture(w io.Writer, n int) {
+	fmt.Fprintf(w, "/* Generated fixture with %d entries */\n", n)
+	key := 0
+	for i := 0; i < n; i++ {
+		if i%500 == 0 {
+			fmt.Fprintf(w, "\n/* MARK: Section %d */\n", i/500+1)
+		}
+		if i > 0 && i%20 == 0 {
+			value := fixtureValue(key - 1)
+			if i%40 == 0 {
+				value = fmt.Sprintf("Changed value %d", key-1)
+			}
+			fmt.Fprintf(w, "\"fixture.key.%d\" = \"%s\";\n", key-1, value)
+			continue
+		}
+		if key%10 == 0 {
+			fmt.Fprintf(w, "// Comment for entry %d\n", key)
+		}
+		fmt.Fprintf(w, "\"fixture.key.%d\" = \"%s\";\n", key, fixtureValue(key))
+		key++
+	}
+}
+
+// fixtureValue is the value writeFixture gives key i
+func fixtureValue(i int) string {
+	if i%7 == 0 {
+		return fmt.Sprintf("%%d items in %%@ (%d)", i)
+	}
+	return fmt.Sprintf("Value number %d", i)
+}
+
+func TestFixture(t *testing.T) {
+	var fixture bytes.Buffer
+	writeFixture(&fixture, 1000)
+	result, err := analyzer.Parse("fixture.strings", &fixture, analyzer.ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Entries) != 1000 || len(result.DuplicateKeys) != 49 || countConflicts(result.DuplicateKeys) != 24 {
+		t.Errorf("got %d entries, %d duplicate keys, %d conflicts; want 1000, 49, 24",
+			len(result.Entries), len(result.DuplicateKeys), countConflicts(result.DuplicateKeys))
+	}
+	// Keys are numbered without gaps
+	for i := range result.KeyOrder {
+		if want := fmt.Sprintf("fixture.key.%d", i); result.KeyOrder[i] != want {
+			t.Fatalf("key %d is %q, want %q", i, result.KeyOrder[i], want)
+		}
+	}
+}
+
+func benchmarkParse(b *testing.B, n int) {
+	var fixture bytes.Buffer
+	writeFixture(&fixture, n)
+	b.SetBytes(int64(fixture.Len()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := analyzer.Parse("fixture.strings", bytes.NewReader(fixture.Bytes()), analyzer.ParseOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse1K(b *testing.B)   { benchmarkParse(b, 1000) }
+func BenchmarkParse10K(b *testing.B)  { benchmarkParse(b, 10000) }
+func BenchmarkParse100K(b *testing.B) { benchmarkParse(b, 100000) }
+
+// writeFixtureDir writes files fixture files of n entries each to a new
+// temporary directory and returns their paths
+func writeFixtureDir(tb testing.TB, files, n int) []string {
+	tb.Helper()
+	dir := tb.TempDir()
+	var paths []string
+	for i := 0; i < files; i++ {
+		var fixture bytes.Buffer
+		writeFixture(&fixture, n)
+		path := filepath.Join(dir, fmt.Sprintf("File%d.strings", i))
+		if err := os.WriteFile(path, fixture.Bytes(), 0644); err != nil {
+			tb.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestScanCacheKeepsResults(t *testing.T) {
+	paths := writeFixtureDir(t, 2, 200)
+	cacheFile := filepath.Join(t.TempDir(), "cache")
+	opts := analyzer.ParseOptions{}
+
+	cold := newScanCache(opts)
+	var want []*analyzer.Result
+	for _, path := range paths {
+		result, err := cold.analyze(path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, result)
+	}
+	if err := cold.save(cacheFile); err != nil {
+		t.Fatal(err)
+	}
+
+	warm, err := loadScanCache(cacheFile, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, path := range paths {
+		got, err := warm.analyze(path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Entries, want[i].Entries) || !reflect.DeepEqual(got.KeyOrder, want[i].KeyOrder) ||
+			!reflect.DeepEqual(got.UniqueEntries, want[i].UniqueEntries) || !reflect.DeepEqual(got.DuplicateKeys, want[i].DuplicateKeys) {
+			t.Errorf("%s: cached result differs from the parsed one", path)
+		}
+	}
+	if warm.hits != len(paths) {
+		t.Errorf("%d cache hits, want %d", warm.hits, len(paths))
+	}
+
+	// Files the next scan doesn't reach are dropped from the cache
+	pruned, err := loadScanCache(cacheFile, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pruned.analyze(paths[0], opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := pruned.save(cacheFile); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := loadScanCache(cacheFile, opts); err != nil {
+		t.Fatal(err)
+	} else if len(reloaded.Files) != 1 {
+		t.Errorf("cache keeps %d files after a scan of 1", len(reloaded.Files))
+	}
+
+	// Every parse option invalidates the cache
+	for _, changed := range []analyzer.ParseOptions{
+		{Encoding: "latin1"}, {Separator: ":"}, {Quote: "any"}, {IncludeCommented: true}, {MaxLineSize: 1024}, {Format: "po"},
+	} {
+		cache, err := loadScanCache(cacheFile, changed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cache.Files) != 0 {
+			t.Errorf("cache reused with options %+v", changed)
+		}
+	}
+}
+
+// BenchmarkDirScan compares scanning 100 files without the cache and with
+// a cache built by an earlier scan
+func BenchmarkDirScan(b *testing.B) {
+	paths := writeFixtureDir(b, 100, 1000)
+	opts := analyzer.ParseOptions{}
+	cacheFile := filepath.Join(b.TempDir(), "cache")
+	cache := newScanCache(opts)
+	for _, path := range paths {
+		if _, err := cache.analyze(path, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := cache.save(cacheFile); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, path := range paths {
+				if _, err := analyzeLocalizationFile(path, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("Cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cache, err := loadScanCache(cacheFile, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, path := range paths {
+				if _, err := cache.analyze(path, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestSummaryOnlyWithWarmCache(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", duplicatesFile)
+	if err := os.WriteFile(filepath.Join(dir, "Other.strings"), []byte("\"x\" = \"1\";\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-dir", ".", "-summary-only", "-cache", filepath.Join(t.TempDir(), "cache")}
+
+	cold, _ := runAnalyzer(t, dir, args...)
+	warm, _ := runAnalyzer(t, dir, args...)
+	if !strings.Contains(warm, "Reused cached results for 2 files.") {
+		t.Fatalf("second scan didn't use the cache:\n%s", warm)
+	}
+
+	totals := func(out string) string {
+		return out[:strings.Index(out, "Reused cached results")]
+	}
+	if !strings.Contains(cold, "Total entries: 5\nUnique keys: 3\n") {
+		t.Errorf("wrong totals without the cache:\n%s", cold)
+	}
+	if totals(warm) != totals(cold) {
+		t.Errorf("totals differ with a warm cache:\ncold:\n%s\nwarm:\n%s", cold, warm)
+	}
+}
+
+func TestReplaceValueKeepsTrailingComment(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", "\"k\" = \"Hi\"; // old: \"Hi\"\n")
+
+	if _, code := runAnalyzer(t, dir, "-replace-value", "Hi=Hello", "-clean", "clean.strings"); code != 0 {
+		t.Fatalf("exit status %d, want 0", code)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "clean.strings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\"k\" = \"Hello\"; // old: \"Hi\"\n"; string(data) != want {
+		t.Errorf("cleaned file %q, want %q", data, want)
+	}
+}
+
+func TestRepairLine(t *testing.T) {
+	tests := []struct {
+		line, quote string
+		want        string
+	}{
+		{`"a"  =  "1"`, "double", `"a"  =  "1";`},
+		{`  "a"="1"  `, "double", `  "a"="1";  `},
+		{"\"a\" =\t\"1\";", "double", "\"a\" =\t\"1\";"},
+		{`"a" = "say "hi""`, "double", `"a" = "say \"hi\"";`},
+		{`'a' = 'it's'`, "single", `'a' = 'it\'s';`},
+		{`'a' = "1"`, "any", `'a' = "1";`},
+		{`'a' = '1'`, "double", `'a' = '1'`},
+		{`"a" = "1" // note`, "double", `"a" = "1" // note`},
+	}
+	for _, test := range tests {
+		if got, _ := repairLine(test.line, "=", test.quote); got != test.want {
+			t.Errorf("repairLine(%q, %s) = %q, want %q", test.line, test.quote, got, test.want)
+		}
+	}
+}
+
+func TestRepairKeepsCRLF(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", "\"a\" = \"1\"\r\n\"b\" = \"2\";\r\n")
+
+	if _, code := runAnalyzer(t, dir, "-repair", "repaired.strings"); code != 0 {
+		t.Fatalf("exit status %d, want 0", code)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "repaired.strings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\"a\" = \"1\";\r\n\"b\" = \"2\";\r\n"; string(data) != want {
+		t.Errorf("repaired file %q, want %q", data, want)
+	}
+}
+
+func TestGraphemeCount(t *testing.T) {
+	tests := []struct {
+		name, s string
+		want    int
+	}{
+		{"ascii", "Hello", 5},
+		{"combining marks", "e\u0301te\u0301", 3},
+		{"zwj family", "👩‍👩‍👧", 1},
+		{"zwj with skin tone", "👩🏽‍💻 ok", 4},
+		{"flag", "🇫🇷", 1},
+		{"two flags", "🇫🇷🇩🇪", 2},
+		{"hangul jamo", "\u1100\u1161\u11A8", 1},
+		{"crlf", "a\r\nb", 3},
+	}
+	for _, test := range tests {
+		if got := graphemeCount(test.s); got != test.want {
+			t.Errorf("%s: graphemeCount(%q) = %d, want %d", test.name, test.s, got, test.want)
+		}
+	}
+}
+
+func TestCleanKeepsUTF8NextToLatin1(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", "\"a\" = \"caf\xe9\";\n\"b\" = \"日本\";\n\"a\" = \"x\";\n")
+
+	if _, code := runAnalyzer(t, dir, "-clean", "clean.strings"); code != 0 {
+		t.Fatalf("exit status %d, want 0", code)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "clean.strings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\"a\" = \"caf\xe9\";\n\"b\" = \"日本\";\n"; string(data) != want {
+		t.Errorf("cleaned file %q, want %q", data, want)
+	}
+
+	// Text the explicit encoding can't hold fails the run
+	out, code := runAnalyzer(t, dir, "-encoding", "latin1", "-replace-value", "caf=日本", "-clean", "clean.strings")
+	if code != 1 || !strings.Contains(out, "can't be written as windows-1252") {
+		t.Errorf("exit status %d, output:\n%s", code, out)
+	}
+}
+
+func TestIgnoreInDirScan(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", duplicatesFile)
+	if err := os.WriteFile(filepath.Join(dir, "ignore.txt"), []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, status := runAnalyzer(t, dir, "-dir", ".", "-ignore", "ignore.txt", "-fail-on-duplicates")
+	if status != 0 {
+		t.Errorf("exit status %d, want 0 with every duplicate ignored:\n%s", status, out)
+	}
+	if !strings.Contains(out, "0 with duplicate keys") {
+		t.Errorf("ignored keys counted as duplicates:\n%s", out)
+	}
+}
+
+func TestSplitReportsDefaultNamespaceCollision(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", "\"Default.title\" = \"Title\";\n\"ok\" = \"OK\";\n")
+
+	out, code := runAnalyzer(t, dir, "-split", "split")
+	if code != 1 || !strings.Contains(out, `key "Default.title"`) {
+		t.Errorf("exit status %d, output:\n%s", code, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "split")); !os.IsNotExist(err) {
+		t.Errorf("split directory written despite the collision")
+	}
+
+	// Without keys lacking a namespace, Default is an ordinary namespace
+	dir = writeTestFile(t, "Localizable.strings", "\"Default.title\" = \"Title\";\n")
+	if out, code := runAnalyzer(t, dir, "-split", "split"); code != 0 {
+		t.Errorf("exit status %d, output:\n%s", code, out)
+	}
+}
+
+func TestSARIFNamesFileOfFirstDefinition(t *testing.T) {
+	dir := writeTestFile(t, "Localizable.strings", "\"a\" = \"1\";\n\"a\" = \"2\";\n#include \"Other.strings\"\n")
+	if err := os.WriteFile(filepath.Join(dir, "Other.strings"), []byte("\"a\" = \"1\";\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runAnalyzer(t, dir, "-follow-includes", "-sarif", "report.sarif")
+	data, err := os.ReadFile(filepath.Join(dir, "report.sarif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`Key \"a\" is already defined at line 1 with a different value`,
+		`Key \"a\" is already defined at line 1 of Localizable.strings with the same value.`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("SARIF report doesn't contain %s:\n%s", want, data)
+		}
+	}
+}